@@ -0,0 +1,415 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+const (
+	// File to store alertList
+	AlertListFilePath = "./alerts.json"
+
+	// Resolved alerts and notifications are forgotten after this duration
+	AlertResolvedRetention = 24 * time.Hour
+)
+
+/*
+	Alert status
+*/
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+/*
+	State of an alert
+
+	Source is the name of what raised the alert when several sources raise
+	the same event type for a target (like "rule:NAME" for alert rules), each
+	source has its own state.
+	Since is the time of the last status change and LastNotified the last
+	time the alert was sent to the transports. An acknowledged firing alert
+	is not sent again until it is resolved or its severity changes.
+	Silenced is true if the firing alert wasn't sent because of a silence: it
+	is sent by the next event after the silence.
+*/
+type AlertState struct {
+	Type           int
+	TypeName       string
+	Probe          string
+	Target         string
+	Source         string
+	Status         string
+	Severity       int
+	Since          time.Time
+	LastNotified   time.Time
+	Silenced       bool
+	Event          dguard.Event
+	Acknowledged   bool
+	AcknowledgedBy string
+	AcknowledgedAt time.Time
+	Comment        string
+}
+
+var (
+	// map[TYPE|PROBE_NAME|TARGET(|SOURCE)] => AlertState
+	alertList map[string]*AlertState
+	// alertList's Mutex
+	AlertListMutex sync.Mutex
+	// Notifications: event types which are never resolved
+	notificationTypes = map[int]bool{
+		dguard.EventContainerCreated: true,
+		dguard.EventContainerRemoved: true,
+		EventContainerReplaced:       true,
+		EventContainerImageChanged:   true,
+	}
+)
+
+/*
+	Initialize alerts controller
+*/
+func InitAlertsController() {
+	// Make map
+	alertList = make(map[string]*AlertState)
+
+	// Check if AlertListFilePath exists
+	if utils.FileExists(AlertListFilePath) {
+		// Load alertList from the file
+		err := LoadAlertsFromFile()
+		if err != nil {
+			l.Critical("Can't load alerts list from file:", err)
+		}
+	}
+}
+
+/*
+	Load alertList from a file
+*/
+func LoadAlertsFromFile() error {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	// Read the file
+	content, err := utils.FileReadAllBytes(AlertListFilePath)
+	if err != nil {
+		return errors.New("LoadAlertsFromFile: Failed to read list in file: " + err.Error())
+	}
+
+	// Parse the file
+	err = json.Unmarshal(content, &alertList)
+	if err != nil {
+		return errors.New("LoadAlertsFromFile: Failed to unmarshal struct: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+	Save alertList to a file
+	(alertList must be locked by the caller)
+*/
+func saveAlertsToFile() error {
+	// Forget old resolved alerts and notifications
+	for key, a := range alertList {
+		if (a.Status == AlertResolved || notificationTypes[a.Type]) && time.Since(a.Since) > AlertResolvedRetention {
+			delete(alertList, key)
+		}
+	}
+
+	// alertList => json
+	tmpJSON, err := json.Marshal(alertList)
+	if err != nil {
+		return errors.New("SaveAlertsToFile: Failed to marshal struct: " + err.Error())
+	}
+
+	// Write json to file
+	err = utils.FileWriteAllBytes(AlertListFilePath, tmpJSON)
+	if err != nil {
+		return errors.New("SaveAlertsToFile: Failed to write list in file: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+	Save alertList to a file
+*/
+func SaveAlertsToFile() error {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	return saveAlertsToFile()
+}
+
+/*
+	Return the alertList key of an event raised by a source
+*/
+func alertKey(eventType int, probeName string, target string, source string) string {
+	if source != "" {
+		return utils.I2S(eventType) + "|" + probeName + "|" + target + "|" + source
+	}
+	return utils.I2S(eventType) + "|" + probeName + "|" + target
+}
+
+/*
+	Get the state of an event's alert raised by a source
+*/
+func GetAlertState(source string, event dguard.Event) (AlertState, bool) {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	a, ok := alertList[alertKey(event.Type, event.Probe, event.Target, source)]
+	if !ok {
+		return AlertState{}, false
+	}
+	return *a, true
+}
+
+/*
+	Set an event's alert as pending if it isn't already pending or firing,
+	and return the time since when it is pending or firing
+*/
+func SetAlertPending(source string, event dguard.Event) time.Time {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	key := alertKey(event.Type, event.Probe, event.Target, source)
+	a, ok := alertList[key]
+	if ok && a.Status != AlertResolved {
+		return a.Since
+	}
+
+	a = &AlertState{
+		Type:     event.Type,
+		TypeName: EventTypeToString(event),
+		Probe:    event.Probe,
+		Target:   event.Target,
+		Source:   source,
+		Status:   AlertPending,
+		Severity: event.Severity,
+		Since:    time.Now(),
+		Event:    event,
+	}
+	alertList[key] = a
+	saveAlertsToFile()
+
+	return a.Since
+}
+
+/*
+	Delete a pending alert
+*/
+func DeletePendingAlert(source string, event dguard.Event) {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	key := alertKey(event.Type, event.Probe, event.Target, source)
+	if a, ok := alertList[key]; ok && a.Status == AlertPending {
+		delete(alertList, key)
+		saveAlertsToFile()
+	}
+}
+
+/*
+	Update the state of an event's alert and return true if the alert must
+	be sent (never if it's silenced)
+
+	A firing alert is sent again only if its severity changed, if it was
+	silenced or if the repeat interval is elapsed since the last
+	notification.
+*/
+func UpdateAlertState(source string, event dguard.Event, silenced bool) bool {
+	var now = time.Now()
+
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	key := alertKey(event.Type, event.Probe, event.Target, source)
+	a, ok := alertList[key]
+
+	// Duplicate of a firing alert
+	if ok && a.Status == AlertFiring && a.Severity == event.Severity {
+		a.Event = event
+		if silenced {
+			return false
+		}
+		repeat := GetConfig().DockerGuard.Event.repeatInterval
+		if !a.Silenced && (a.Acknowledged || repeat == 0 || now.Sub(a.LastNotified) < repeat) {
+			return false
+		}
+		a.Silenced = false
+		a.LastNotified = now
+		saveAlertsToFile()
+		return true
+	}
+
+	if !ok || a.Status != AlertFiring {
+		a = &AlertState{
+			Type:     event.Type,
+			TypeName: EventTypeToString(event),
+			Probe:    event.Probe,
+			Target:   event.Target,
+			Source:   source,
+			Since:    now,
+		}
+		alertList[key] = a
+	}
+	a.Status = AlertFiring
+	a.Acknowledged = false
+	a.Severity = event.Severity
+	a.Silenced = silenced
+	if !silenced {
+		a.LastNotified = now
+	}
+	a.Event = event
+
+	// Resolve the opposite alerts
+	resolveOppositeAlerts(event, now)
+	saveAlertsToFile()
+
+	return !silenced
+}
+
+/*
+	Resolve the alerts made obsolete by an event
+	(alertList must be locked by the caller)
+*/
+func resolveOppositeAlerts(event dguard.Event, now time.Time) {
+	var opposite = -1
+
+	switch event.Type {
+	case dguard.EventContainerStarted:
+		opposite = dguard.EventContainerStopped
+	case dguard.EventContainerStopped:
+		opposite = dguard.EventContainerStarted
+	case dguard.EventContainerCreated:
+		opposite = dguard.EventContainerRemoved
+	case EventProbeUnreachable:
+		opposite = EventProbeRecovered
+	case EventProbeRecovered:
+		opposite = EventProbeUnreachable
+	}
+
+	for key, a := range alertList {
+		if a.Probe != event.Probe || a.Target != event.Target || a.Type == event.Type {
+			continue
+		}
+		// A removed container resolves all its alerts
+		if a.Type != opposite && event.Type != dguard.EventContainerRemoved {
+			continue
+		}
+		if a.Status == AlertPending {
+			delete(alertList, key)
+		} else if a.Status == AlertFiring {
+			a.Status = AlertResolved
+			a.Since = now
+		}
+	}
+}
+
+/*
+	Resolve the alerts of a removed container without sending them
+	(like a ContainerRemoved event)
+*/
+func ResolveContainerAlerts(probeName string, target string) {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	resolveOppositeAlerts(dguard.Event{Type: dguard.EventContainerRemoved, Target: target, Probe: probeName}, time.Now())
+	saveAlertsToFile()
+}
+
+/*
+	Resolve a firing alert and return its last firing event, and true if the
+	resolved event must be sent (if the firing alert was sent)
+*/
+func ResolveAlertState(source string, event dguard.Event) (dguard.Event, bool) {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	a, ok := alertList[alertKey(event.Type, event.Probe, event.Target, source)]
+	if !ok || a.Status != AlertFiring {
+		return event, false
+	}
+
+	firingEvent := a.Event
+	notified := !a.LastNotified.IsZero()
+	a.Status = AlertResolved
+	a.Since = time.Now()
+	a.Event = event
+	saveAlertsToFile()
+
+	return firingEvent, notified
+}
+
+/*
+	Forget the alerts of a deleted probe
+*/
+func DeleteProbeAlerts(probeName string) {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	for key, a := range alertList {
+		if a.Probe == probeName {
+			delete(alertList, key)
+		}
+	}
+	saveAlertsToFile()
+}
+
+/*
+	Get the list of alerts, filtered by status if it isn't empty
+*/
+func GetAlertStates(status string) []AlertState {
+	var alerts []AlertState // Alerts to return
+
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	for _, a := range alertList {
+		if status == "" || a.Status == status {
+			alerts = append(alerts, *a)
+		}
+	}
+
+	return alerts
+}
+
+/*
+	Acknowledge a firing alert
+*/
+func AcknowledgeAlert(eventType int, probeName string, target string, source string, by string, comment string) (AlertState, error) {
+	// Lock / Unlock alertList
+	AlertListMutex.Lock()
+	defer AlertListMutex.Unlock()
+
+	a, ok := alertList[alertKey(eventType, probeName, target, source)]
+	if !ok || a.Status != AlertFiring {
+		return AlertState{}, errors.New("Not found")
+	}
+
+	a.Acknowledged = true
+	a.AcknowledgedBy = by
+	a.AcknowledgedAt = time.Now()
+	a.Comment = comment
+	saveAlertsToFile()
+
+	return *a, nil
+}
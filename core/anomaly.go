@@ -0,0 +1,280 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+const (
+	// Default z-score of an anomaly
+	DefaultAnomalyZScore = 3
+	// Default smoothing factor of the baselines
+	DefaultAnomalyAlpha = 0.05
+	// Default number of samples before detecting anomalies
+	DefaultAnomalyMinSamples = 30
+	// Default number of consecutive anomalous samples before a new baseline
+	DefaultAnomalyRebaseline = 120
+	// Default history used to seed the baselines at startup
+	DefaultAnomalySeed = time.Hour
+)
+
+/*
+	Anomaly detector config
+
+	The detector keeps an exponentially weighted moving mean and standard
+	deviation (the baseline) of each metric of each running container, and
+	sends a MetricAnomaly alert when the z-score of a sample, its distance to
+	the mean in standard deviations, is above ZScore. Alpha (0 < Alpha < 1)
+	is the weight of a new sample in the baseline. Anomalous samples are not
+	added, but after Rebaseline consecutive anomalous samples the level
+	shift is the new normal: the baseline is learned again from it. The
+	baselines are seeded from the stats of the last Seed duration at startup.
+*/
+type AnomalyConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Metrics    []string `yaml:"metrics"`
+	ZScore     float64  `yaml:"z-score"`
+	Alpha      float64  `yaml:"alpha"`
+	MinSamples int      `yaml:"min-samples"`
+	Rebaseline int      `yaml:"rebaseline"`
+	Seed       string   `yaml:"seed"`
+	seed       time.Duration
+}
+
+/*
+	Baseline of a metric of a container
+*/
+type baseline struct {
+	Samples   int
+	Mean      float64
+	Variance  float64
+	Anomalous int // Consecutive anomalous samples
+}
+
+var (
+	// map[PROBE_NAME|CONTAINER_ID] => map[METRIC] => baseline
+	baselines = make(map[string]map[string]*baseline)
+	// baselines's Mutex
+	baselinesMutex sync.Mutex
+)
+
+/*
+	Check the anomaly detector config and set the default values
+*/
+func (c *AnomalyConfig) Check() error {
+	var err error // Error handling
+
+	if len(c.Metrics) == 0 {
+		c.Metrics = []string{MetricCPUUsage, MetricSizeMemory, MetricNetBandwithRX, MetricNetBandwithTX}
+	}
+	for _, metric := range c.Metrics {
+		if _, _, err = ContainerMetric(&dguard.Container{}, metric); err != nil {
+			return err
+		}
+	}
+	if c.ZScore == 0 {
+		c.ZScore = DefaultAnomalyZScore
+	}
+	if c.ZScore < 0 {
+		return errors.New("Bad z-score: must be positive")
+	}
+	if c.Alpha == 0 {
+		c.Alpha = DefaultAnomalyAlpha
+	}
+	if c.Alpha < 0 || c.Alpha >= 1 {
+		return errors.New("Bad alpha: must be between 0 and 1")
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = DefaultAnomalyMinSamples
+	}
+	if c.Rebaseline <= 0 {
+		c.Rebaseline = DefaultAnomalyRebaseline
+	}
+	c.seed = DefaultAnomalySeed
+	if c.Seed != "" {
+		c.seed, err = time.ParseDuration(c.Seed)
+		if err != nil {
+			return errors.New("Bad seed: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+/*
+	Return the z-score of a sample (0 if the baseline has no variance)
+*/
+func (b *baseline) ZScore(value float64) float64 {
+	if b.Samples == 0 || b.Variance <= 0 {
+		return 0
+	}
+	return (value - b.Mean) / math.Sqrt(b.Variance)
+}
+
+/*
+	Add a sample to the baseline
+*/
+func (b *baseline) Add(value float64, alpha float64) {
+	if b.Samples == 0 {
+		b.Mean = value
+		b.Samples++
+		return
+	}
+
+	diff := value - b.Mean
+	incr := alpha * diff
+	b.Mean += incr
+	b.Variance = (1 - alpha) * (b.Variance + diff*incr)
+	b.Samples++
+}
+
+/*
+	Seed the baselines with the stats stored in InfluxDB
+*/
+func InitAnomalyDetector() {
+	var conf = GetConfig().DockerGuard.Anomaly
+	var samples int
+
+	if !conf.Enabled {
+		return
+	}
+
+	query := "SELECT running, " + strings.Join(conf.Metrics, ", ") + " FROM " + StatsMeasurements +
+		fmt.Sprintf(" WHERE time > now() - %ds GROUP BY containerid, probename", int(conf.seed.Seconds()))
+
+	// Send query
+	l.Debug("InitAnomalyDetector: InfluxDB query:", query)
+	res, err := queryDB(DB, query)
+	if err != nil {
+		l.Error("InitAnomalyDetector: Can't seed baselines:", err)
+		return
+	}
+	if len(res) < 1 {
+		return
+	}
+
+	// Lock / Unlock baselines
+	baselinesMutex.Lock()
+	defer baselinesMutex.Unlock()
+
+	for _, serie := range res[0].Series {
+		var key = serie.Tags["probename"] + "|" + serie.Tags["containerid"]
+		var columns = make(map[string]int)
+		for i, c := range serie.Columns {
+			columns[c] = i
+		}
+		if baselines[key] == nil {
+			baselines[key] = make(map[string]*baseline)
+		}
+
+		for _, row := range serie.Values {
+			if i, ok := columns["running"]; !ok || row[i] != true {
+				continue
+			}
+			for _, metric := range conf.Metrics {
+				i, ok := columns[metric]
+				if !ok || row[i] == nil {
+					continue
+				}
+				n, ok := row[i].(json.Number)
+				if !ok {
+					continue
+				}
+				value, err := n.Float64()
+				if err != nil {
+					continue
+				}
+				b, ok := baselines[key][metric]
+				if !ok {
+					b = new(baseline)
+					baselines[key][metric] = b
+				}
+				b.Add(value, conf.Alpha)
+				samples++
+			}
+		}
+	}
+	l.Verbose("Anomaly detector: baselines seeded with", samples, "samples")
+}
+
+/*
+	Add the metrics of a container to its baselines, and send a
+	MetricAnomaly alert when a metric deviates from its baseline
+	(the alert is resolved when every metric is back to normal)
+*/
+func CheckAnomalies(probeName string, c *dguard.Container) {
+	var conf = GetConfig().DockerGuard.Anomaly
+	var anomalies []string // Anomalies descriptions
+	var key = probeName + "|" + c.ID
+
+	if !conf.Enabled || !c.Running {
+		return
+	}
+
+	// Lock / Unlock baselines
+	baselinesMutex.Lock()
+	if baselines[key] == nil {
+		baselines[key] = make(map[string]*baseline)
+	}
+	for _, metric := range conf.Metrics {
+		value, _, err := ContainerMetric(c, metric)
+		if err != nil {
+			continue
+		}
+		b, ok := baselines[key][metric]
+		if !ok {
+			b = new(baseline)
+			baselines[key][metric] = b
+		}
+		zScore := b.ZScore(value)
+		if b.Samples >= conf.MinSamples && math.Abs(zScore) > conf.ZScore {
+			b.Anomalous++
+			if b.Anomalous < conf.Rebaseline {
+				anomalies = append(anomalies, fmt.Sprintf("%s: %.2f (mean: %.2f, stddev: %.2f, z-score: %.1f)",
+					metric, value, b.Mean, math.Sqrt(b.Variance), zScore))
+				continue
+			}
+
+			// Level shift: learn a new baseline
+			l.Info("Anomaly detector: new", metric, "baseline for", c.Hostname, "("+probeName+")")
+			*b = baseline{}
+		}
+		b.Anomalous = 0
+		b.Add(value, conf.Alpha)
+	}
+	baselinesMutex.Unlock()
+
+	var event = dguard.Event{
+		Severity: dguard.EventWarning,
+		Type:     EventMetricAnomaly,
+		Target:   c.Hostname + " (" + c.ID + ")",
+		Probe:    probeName,
+		Data:     strings.Join(anomalies, ", ")}
+	if len(anomalies) > 0 {
+		Alert(event)
+		return
+	}
+
+	// The alert state is persisted: the alert is resolved even after a restart
+	if a, ok := GetAlertState("", event); ok && a.Status == AlertFiring {
+		ResolveAlert(event)
+	}
+}
+
+/*
+	Forget the baselines of a removed container
+*/
+func DeleteBaselines(probeName string, containerID string) {
+	// Lock / Unlock baselines
+	baselinesMutex.Lock()
+	defer baselinesMutex.Unlock()
+
+	delete(baselines, probeName+"|"+containerID)
+}
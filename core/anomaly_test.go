@@ -0,0 +1,73 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestAnomalies(t *testing.T) {
+	var c = dguard.Container{ID: "abc123", Hostname: "web-1", Running: true}
+	var event = dguard.Event{Type: EventMetricAnomaly, Target: "web-1 (abc123)", Probe: "probe1"}
+
+	// Watch every container and check the CPU usage only
+	var conf = AnomalyConfig{Enabled: true, Metrics: []string{MetricCPUUsage}, Rebaseline: 50}
+	err := conf.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+		c.DockerGuard.Anomaly = conf
+	})()
+	alertList = make(map[string]*AlertState)
+	defer DeleteBaselines("probe1", c.ID)
+
+	// Learn a baseline around 10%
+	for i := 0; i < 100; i++ {
+		c.CPUUsage = 10 + float64(i%3)
+		CheckAnomalies("probe1", &c)
+	}
+	if _, ok := GetAlertState("", event); ok {
+		t.Fatal("alert sent while learning the baseline")
+	}
+
+	// A sustained anomaly keeps firing until the level shift is the new normal
+	for i := 0; i < 49; i++ {
+		c.CPUUsage = 90 + float64(i%3)
+		CheckAnomalies("probe1", &c)
+		if a, ok := GetAlertState("", event); !ok || a.Status != AlertFiring {
+			t.Fatalf("sample %d: anomaly not firing", i)
+		}
+	}
+	c.CPUUsage = 90
+	CheckAnomalies("probe1", &c)
+	if a, _ := GetAlertState("", event); a.Status != AlertResolved {
+		t.Fatalf("anomaly %s after the new baseline, expected resolved", a.Status)
+	}
+
+	// Learn the new baseline, the old level is an anomaly
+	for i := 0; i < 100; i++ {
+		c.CPUUsage = 90 + float64(i%3)
+		CheckAnomalies("probe1", &c)
+	}
+	if a, _ := GetAlertState("", event); a.Status != AlertResolved {
+		t.Fatalf("anomaly %s while learning the new baseline", a.Status)
+	}
+	c.CPUUsage = 10
+	CheckAnomalies("probe1", &c)
+	if a, _ := GetAlertState("", event); a.Status != AlertFiring {
+		t.Fatalf("anomaly %s, expected firing", a.Status)
+	}
+
+	// Back to normal after a restart: the persisted alert is resolved
+	DeleteBaselines("probe1", c.ID)
+	for i := 0; i < 5; i++ {
+		c.CPUUsage = 10 + float64(i%3)
+		CheckAnomalies("probe1", &c)
+	}
+	if a, _ := GetAlertState("", event); a.Status != AlertResolved {
+		t.Errorf("anomaly %s, expected resolved", a.Status)
+	}
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"../utils"
+)
+
+var (
+	// Reloads' Mutex
+	configReloadMutex sync.Mutex
+)
+
+/*
+	Reload the config on SIGHUP, and when the config file is modified if
+	the config-reload interval is set
+*/
+func WatchConfig() {
+	var signals = make(chan os.Signal, 1)
+	var lastModTime time.Time
+
+	signal.Notify(signals, syscall.SIGHUP)
+	if info, err := os.Stat(configPath); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	for {
+		var check <-chan time.Time
+		if interval := GetConfig().DockerGuard.configReload; interval > 0 {
+			check = time.After(interval)
+		}
+
+		select {
+		case <-signals:
+			l.Info("SIGHUP received, reloading config")
+		case <-check:
+			info, err := os.Stat(configPath)
+			if err != nil || !info.ModTime().After(lastModTime) {
+				continue
+			}
+			l.Info("Config file modified, reloading config")
+		}
+
+		if info, err := os.Stat(configPath); err == nil {
+			lastModTime = info.ModTime()
+		}
+		err := ReloadConfig()
+		if err != nil {
+			l.Error("Can't reload config:", err)
+			continue
+		}
+		l.Info("Config reloaded")
+	}
+}
+
+/*
+	Load the config file again and apply it, without losing the state of
+	the monitor: the new config is checked first, and only the transports and
+	probes which changed are restarted
+	(the other settings, like the retries, are read when they are used)
+
+	The probes of the config are only applied if the probes list isn't
+	saved in ProbeListFilePath: like at startup, this file replaces them.
+*/
+func ReloadConfig() error {
+	// Lock / Unlock reloads
+	configReloadMutex.Lock()
+	defer configReloadMutex.Unlock()
+
+	c, err := LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	old := GetConfig()
+
+	// Make the new transports (the unchanged ones are kept)
+	newTransports, err := BuildTransports(c.DockerGuard.Event.Transports, GetTransports())
+	if err != nil {
+		return err
+	}
+
+	// Warn about the changes which need a restart
+	if c.DockerGuard.API.ListenInterface != old.DockerGuard.API.ListenInterface ||
+		c.DockerGuard.API.ListenPort != old.DockerGuard.API.ListenPort {
+		l.Warn("ReloadConfig: API listen address changed, restart needed")
+	}
+	if !reflect.DeepEqual(c.DockerGuard.InfluxDB, old.DockerGuard.InfluxDB) {
+		l.Warn("ReloadConfig: InfluxDB config changed, restart needed")
+	}
+	if c.DockerGuard.Event.Workers != old.DockerGuard.Event.Workers ||
+		c.DockerGuard.Event.QueueSize != old.DockerGuard.Event.QueueSize {
+		l.Warn("ReloadConfig: dispatcher workers or queue-size changed, restart needed")
+	}
+
+	// Apply the new config
+	setConfig(&c)
+	SetTransports(newTransports)
+	ResolveRemovedRulesAlerts()
+
+	if utils.FileExists(ProbeListFilePath) {
+		if !reflect.DeepEqual(old.Probes, c.Probes) {
+			l.Warn("ReloadConfig: probes changed but not applied, the probes list is managed with the API in", ProbeListFilePath)
+		}
+		return nil
+	}
+	return reloadProbes(old.Probes, c.Probes)
+}
+
+/*
+	Apply the changes of the probes of the config file to the monitored
+	probes (the probes list isn't saved: the config stays its source)
+*/
+func reloadProbes(oldProbes []Probe, newProbes []Probe) error {
+	var errs []string      // Errors
+	var removed []*Probe   // Removed probes
+	var restarted []*Probe // Old probes of the changed probes
+
+	// Lock Probes
+	ProbesMutex.Lock()
+
+	// Removed probes
+	for _, old := range oldProbes {
+		var gone = true
+		for _, p := range newProbes {
+			if p.Name == old.Name {
+				gone = false
+				break
+			}
+		}
+		if gone {
+			p, err := deleteProbe(old.Name)
+			if err == nil {
+				removed = append(removed, p)
+			}
+		}
+	}
+
+	// Added and changed probes
+	for _, p := range newProbes {
+		var changed = true
+		for _, old := range oldProbes {
+			if old.Name == p.Name {
+				changed = !reflect.DeepEqual(old.Config(), p.Config())
+				break
+			}
+		}
+		if !changed {
+			continue
+		}
+		old, err := updateProbe(p)
+		if err == nil {
+			restarted = append(restarted, old)
+		} else if err.Error() == "Not found" {
+			err = addProbe(p)
+		}
+		if err != nil {
+			errs = append(errs, "probe "+p.Name+": "+err.Error())
+		}
+	}
+
+	// Unlock Probes
+	ProbesMutex.Unlock()
+
+	// The monitors may be waiting for Probes
+	for _, p := range removed {
+		p.destroy()
+	}
+	for _, p := range restarted {
+		p.stop()
+	}
+
+	if len(errs) > 0 {
+		return errors.New("Can't reload probes: " + strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestReloadProbes(t *testing.T) {
+	var probe1 = Probe{Name: "probe1", URI: "http://127.0.0.1:1", ReloadTime: 0.05}
+	var probe2 = Probe{Name: "probe2", URI: "http://127.0.0.1:2", ReloadTime: 0.05}
+	var probe3 = Probe{Name: "probe3", URI: "http://127.0.0.1:3", ReloadTime: 0.05}
+
+	containerList = make(map[string]*map[string]*dguard.Container)
+	ProbeLastStats = make(map[string][]dguard.Container)
+	alertList = make(map[string]*AlertState)
+
+	// Added probes
+	err := reloadProbes(nil, []Probe{probe1, probe2, probe3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started = make(map[string]*Probe)
+	for _, p := range GetProbes() {
+		started[p.Name] = p
+	}
+	if len(started) != 3 {
+		t.Fatalf("%d probes started, expected 3", len(started))
+	}
+	ProbeLastStatsMutex.Lock()
+	ProbeLastStats["probe3"] = nil
+	ProbeLastStatsMutex.Unlock()
+
+	// probe1 unchanged, probe2 changed, probe3 removed
+	var changed = probe2
+	changed.ReloadTime = 0.1
+	err = reloadProbes([]Probe{probe1, probe2, probe3}, []Probe{probe1, changed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var probes = make(map[string]*Probe)
+	for _, p := range GetProbes() {
+		probes[p.Name] = p
+	}
+	if len(probes) != 2 || probes["probe1"] != started["probe1"] {
+		t.Fatalf("probes %v, expected probe1 kept and probe2", probes)
+	}
+	if probes["probe2"] == started["probe2"] || probes["probe2"].ReloadTime != 0.1 {
+		t.Error("probe2 not restarted with its new config")
+	}
+	if probes["probe2"].Infos != started["probe2"].Infos || probes["probe2"].Health != started["probe2"].Health {
+		t.Error("probe2 infos or health lost")
+	}
+	for _, name := range []string{"probe2", "probe3"} {
+		select {
+		case <-started[name].done:
+		default:
+			t.Errorf("old %s monitor still running", name)
+		}
+	}
+	ProbeLastStatsMutex.Lock()
+	if _, ok := ProbeLastStats["probe3"]; ok {
+		t.Error("probe3 data not deleted")
+	}
+	ProbeLastStatsMutex.Unlock()
+
+	// Invalid probe
+	err = reloadProbes([]Probe{probe1, changed}, []Probe{probe1, changed, {Name: "bad probe", URI: "http://127.0.0.1:4"}})
+	if err == nil {
+		t.Error("invalid probe accepted")
+	}
+
+	err = reloadProbes([]Probe{probe1, changed}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(GetProbes()) != 0 {
+		t.Error("probes not removed")
+	}
+}
+
+func TestResolveRemovedRulesAlerts(t *testing.T) {
+	var event = dguard.Event{Severity: dguard.EventWarning, Type: dguard.EventCPUUsageOverload, Target: "probe1", Probe: "probe1"}
+
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+		c.DockerGuard.Rules = []Rule{{Name: "cpu", Metric: MetricCPUUsage, Warning: 80}}
+	})()
+	alertList = make(map[string]*AlertState)
+
+	UpdateAlertState("rule:cpu", event, false)
+	UpdateAlertState("rule:load", event, false)
+	SetAlertPending("rule:old", event)
+	UpdateAlertState("", event, false)
+
+	ResolveRemovedRulesAlerts()
+
+	for _, test := range []struct {
+		source string
+		status string
+	}{
+		{"rule:cpu", AlertFiring},
+		{"rule:load", AlertResolved},
+		{"rule:old", ""},
+		{"", AlertFiring},
+	} {
+		a, ok := GetAlertState(test.source, event)
+		if !ok {
+			a.Status = ""
+		}
+		if a.Status != test.status {
+			t.Errorf("%q alert %q, expected %q", test.source, a.Status, test.status)
+		}
+	}
+}
@@ -1,6 +1,13 @@
 package core
 
 import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"../utils"
 	"gopkg.in/yaml.v2"
 )
@@ -22,39 +29,234 @@ type Config struct {
 			DB   string `yaml:"db"`
 		} `yaml:"influxdb"`
 		Event struct {
-			Watch      []string    `yaml:"watch"`
-			Transports []Transport `yaml:"transports"`
+			Watch          []string          `yaml:"watch"`
+			WatchRules     []WatchRule       `yaml:"watch-rules"`
+			IgnoreRules    []WatchRule       `yaml:"ignore-rules"`
+			Severities     []SeverityRule    `yaml:"severities"`
+			Transports     []TransportConfig `yaml:"transports"`
+			RepeatInterval string            `yaml:"repeat-interval"`
+			ProbeFailures  int               `yaml:"probe-failures"`
+			ProbeBackoff   string            `yaml:"probe-backoff"`
+			Workers        int               `yaml:"workers"`
+			QueueSize      int               `yaml:"queue-size"`
+			Retries        int               `yaml:"retries"`
+			FlapChanges    int               `yaml:"flap-changes"`
+			FlapWindow     string            `yaml:"flap-window"`
+			watch          []*regexp.Regexp
+			repeatInterval time.Duration
+			probeBackoff   time.Duration
+			flapWindow     time.Duration
 		} `yaml:"event"`
+		Rules      []Rule           `yaml:"rules"`
+		Anomaly    AnomalyConfig    `yaml:"anomaly"`
+		Forecast   ForecastConfig   `yaml:"forecast"`
+		MemoryLeak MemoryLeakConfig `yaml:"memory-leak"`
+		// Interval between two checks of the config file modification time
+		ConfigReload string `yaml:"config-reload"`
+		configReload time.Duration
 	} `yaml:"docker-guard"`
 	Probes []Probe `yaml:"probes"`
 }
 
 var (
-	// Program config
-	DGConfig Config
+	// Program config (see GetConfig)
+	dgConfig *Config
+	// dgConfig's Mutex
+	ConfigMutex sync.RWMutex
+	// Path of the config file
+	configPath string
 )
 
 /*
 	Load program config from file
 */
 func InitConfig(path string) {
+	c, err := LoadConfig(path)
+	if err != nil {
+		l.Critical(err)
+	}
+	setConfig(&c)
+	configPath = path
+}
+
+/*
+	Get the program config
+	(a reload replaces the config without modifying it: the returned config
+	is a consistent snapshot, which may be kept during a whole operation)
+*/
+func GetConfig() *Config {
+	// Lock / Unlock dgConfig
+	ConfigMutex.RLock()
+	defer ConfigMutex.RUnlock()
+
+	return dgConfig
+}
+
+/*
+	Replace the program config and return the old one
+*/
+func setConfig(c *Config) *Config {
+	// Lock / Unlock dgConfig
+	ConfigMutex.Lock()
+	defer ConfigMutex.Unlock()
+
+	old := dgConfig
+	dgConfig = c
+	return old
+}
+
+/*
+	Load and check a config file
+*/
+func LoadConfig(path string) (Config, error) {
+	var c Config       // Loaded config
 	var content string // Config file content
 	var err error      // Error handling
 
 	// Read config file
 	content, err = utils.FileReadAll(path)
 	if err != nil {
-		l.Critical("Content file read error:", err)
+		return c, errors.New("Content file read error: " + err.Error())
 	}
 
 	// Debug: display config file content
 	l.Debug("Config file content:", "\n"+content)
 
-	// Config file parsing: yaml => core.DGConfig
-	err = yaml.Unmarshal([]byte(content), &DGConfig)
+	// Config file parsing: yaml => Config
+	err = yaml.Unmarshal([]byte(content), &c)
+	if err != nil {
+		return c, errors.New("Config file parsing error: " + err.Error())
+	}
+
+	// Parse alerts repeat interval
+	if c.DockerGuard.Event.RepeatInterval != "" {
+		c.DockerGuard.Event.repeatInterval, err = time.ParseDuration(c.DockerGuard.Event.RepeatInterval)
+		if err != nil {
+			return c, errors.New("Bad event repeat-interval: " + err.Error())
+		}
+	}
+
+	// Parse probes max backoff
+	c.DockerGuard.Event.probeBackoff = DefaultProbeBackoff
+	if c.DockerGuard.Event.ProbeBackoff != "" {
+		c.DockerGuard.Event.probeBackoff, err = time.ParseDuration(c.DockerGuard.Event.ProbeBackoff)
+		if err != nil || c.DockerGuard.Event.probeBackoff <= 0 {
+			return c, errors.New("Bad event probe-backoff: " + c.DockerGuard.Event.ProbeBackoff)
+		}
+	}
+
+	// Parse flapping window
+	c.DockerGuard.Event.flapWindow = DefaultFlapWindow
+	if c.DockerGuard.Event.FlapWindow != "" {
+		c.DockerGuard.Event.flapWindow, err = time.ParseDuration(c.DockerGuard.Event.FlapWindow)
+		if err != nil {
+			return c, errors.New("Bad event flap-window: " + err.Error())
+		}
+	}
+
+	// Compile watch regexps and check watch and ignore rules
+	err = compileWatch(&c)
+	if err != nil {
+		return c, err
+	}
+
+	// Check severity rules
+	for i := range c.DockerGuard.Event.Severities {
+		err = c.DockerGuard.Event.Severities[i].Check()
+		if err != nil {
+			return c, errors.New("Bad severity rule: " + err.Error())
+		}
+	}
+
+	// Check alert rules
+	var ruleNames = make(map[string]bool)
+	for i := range c.DockerGuard.Rules {
+		var r = &c.DockerGuard.Rules[i]
+		err = r.Check()
+		if err != nil {
+			return c, errors.New("Bad alert rule: " + err.Error())
+		}
+		if ruleNames[r.Name] {
+			return c, errors.New("Bad alert rule: duplicate rule name " + r.Name)
+		}
+		ruleNames[r.Name] = true
+	}
+
+	// Check anomaly detector config
+	err = c.DockerGuard.Anomaly.Check()
+	if err != nil {
+		return c, errors.New("Bad anomaly config: " + err.Error())
+	}
+
+	// Check forecast config
+	err = c.DockerGuard.Forecast.Check()
 	if err != nil {
-		l.Critical("error: %v", err)
+		return c, errors.New("Bad forecast config: " + err.Error())
 	}
 
-	l.Silly("DGConfig:\n", DGConfig)
+	// Check memory leak detector config
+	err = c.DockerGuard.MemoryLeak.Check()
+	if err != nil {
+		return c, errors.New("Bad memory-leak config: " + err.Error())
+	}
+
+	// Check config reload interval
+	if c.DockerGuard.ConfigReload != "" {
+		c.DockerGuard.configReload, err = time.ParseDuration(c.DockerGuard.ConfigReload)
+		if err != nil || c.DockerGuard.configReload <= 0 {
+			return c, errors.New("Bad config-reload: " + c.DockerGuard.ConfigReload)
+		}
+	}
+
+	// Check probes
+	var probeNames = make(map[string]bool)
+	for i := range c.Probes {
+		err = c.Probes[i].Check()
+		if err != nil {
+			return c, errors.New("Bad probe: " + err.Error())
+		}
+		if probeNames[c.Probes[i].Name] {
+			return c, errors.New("Bad probe: duplicate probe name " + c.Probes[i].Name)
+		}
+		probeNames[c.Probes[i].Name] = true
+	}
+
+	l.Silly("Config:\n", c)
+
+	return c, nil
+}
+
+/*
+	Compile the watch regexps and check the watch and ignore rules of a config
+	(the error lists every invalid pattern)
+*/
+func compileWatch(c *Config) error {
+	var errs []string // Invalid patterns
+
+	c.DockerGuard.Event.watch = nil
+	for _, pattern := range c.DockerGuard.Event.Watch {
+		rgxp, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, "watch "+strconv.Quote(pattern)+": "+err.Error())
+			continue
+		}
+		c.DockerGuard.Event.watch = append(c.DockerGuard.Event.watch, rgxp)
+	}
+	for i := range c.DockerGuard.Event.WatchRules {
+		if err := c.DockerGuard.Event.WatchRules[i].Check(); err != nil {
+			errs = append(errs, "watch-rules #"+strconv.Itoa(i+1)+": "+err.Error())
+		}
+	}
+	for i := range c.DockerGuard.Event.IgnoreRules {
+		if c.DockerGuard.Event.IgnoreRules[i].Empty() {
+			errs = append(errs, "ignore-rules #"+strconv.Itoa(i+1)+": empty rule (it would ignore every container)")
+		} else if err := c.DockerGuard.Event.IgnoreRules[i].Check(); err != nil {
+			errs = append(errs, "ignore-rules #"+strconv.Itoa(i+1)+": "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New("Bad watch patterns:\n\t" + strings.Join(errs, "\n\t"))
+	}
+	return nil
 }
@@ -137,6 +137,20 @@ func DeleteContainer(c *dguard.Container) error {
 	return nil
 }
 
+/*
+	Delete the containers of a probe in containerList
+*/
+func DeleteContainersByProbe(probeName string) {
+	// Lock / Unlock containerList
+	ContainerListMutex.Lock()
+	defer func() {
+		ContainerListMutex.Unlock()
+		SaveListToFile()
+	}()
+
+	delete(containerList, probeName)
+}
+
 /*
 	Get containers by probe name in containerList
 */
@@ -224,13 +238,25 @@ func GetContainerByCID(cid string) (dguard.Container, error) {
 func GetProbesInfos() []dguard.ProbeInfos {
 	var probes []dguard.ProbeInfos // List of probes infos to return
 
-	for _, probe := range Probes {
+	for _, probe := range GetProbes() {
 		if probe == nil {
 			l.Error("GetProbesInfos: probe can't be nil")
 			continue
 		}
-		probes = append(probes, *((*probe).Infos))
+		probes = append(probes, probe.GetInfos())
 	}
 
 	return probes
 }
+
+/*
+	Get the infos of a probe by name
+*/
+func GetProbeInfos(probeName string) (dguard.ProbeInfos, error) {
+	for _, infos := range GetProbesInfos() {
+		if infos.Name == probeName {
+			return infos, nil
+		}
+	}
+	return dguard.ProbeInfos{}, errors.New("Not found")
+}
@@ -1,69 +1,110 @@
 package core
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
 )
 
 var (
 	// HTTP client used to get probe infos
-	HTTPClient     = &http.Client{}
+	HTTPClient = &http.Client{}
+	// Monitored probes (locked by ProbesMutex)
 	Probes         []*Probe
 	ProbeLastStats map[string][]dguard.Container
+	// ProbeLastStats's Mutex
+	ProbeLastStatsMutex sync.Mutex
+	// Mutex of the probes' Infos (written by their monitors)
+	ProbesInfosMutex sync.Mutex
 )
 
 /*
 	Probe
 */
 type Probe struct {
-	Name        string  `yaml:"name"`
-	URI         string  `yaml:"uri"`
-	APIPassword string  `yaml:"api-password"`
-	ReloadTime  float64 `yaml:"reload-time"`
-	Infos       *dguard.ProbeInfos
+	Name        string             `yaml:"name"`
+	URI         string             `yaml:"uri"`
+	APIPassword string             `yaml:"api-password" json:",omitempty"`
+	ReloadTime  float64            `yaml:"reload-time"`
+	Timeout     float64            `yaml:"timeout"`
+	Infos       *dguard.ProbeInfos `json:"-"`
+	Health      *ProbeHealth       `json:"-"`
+	cancel      context.CancelFunc
+	done        chan struct{}
 }
 
 /*
 	Initialize Core
 */
 func Init() {
+	// Seed the jitter of the probe monitors
+	rand.Seed(time.Now().UnixNano())
+
 	// Init ProbeLastStats map
 	ProbeLastStats = make(map[string][]dguard.Container)
 
 	// Init Containers Controller
 	InitContainersController()
 
+	// Init Alerts Controller
+	InitAlertsController()
+
+	// Init Silences Controller
+	InitSilencesController()
+
+	// Init image history
+	InitImageHistory()
+
 	// Init InfluxDB client
 	InitDB()
 
+	// Init anomaly detector
+	InitAnomalyDetector()
+
+	// Init events history
+	InitEventHistory()
+
+	// Init transports
+	InitTransports()
+
+	// Init alerts dispatcher
+	InitDispatcher()
+
+	// Resolve the alerts of the rules removed while stopped
+	ResolveRemovedRulesAlerts()
+
 	// Launch probe monitors
-	for _, p := range DGConfig.Probes {
-		var probe = Probe{
-			Name:        p.Name,
-			URI:         p.URI,
-			APIPassword: p.APIPassword,
-			ReloadTime:  p.ReloadTime,
-			Infos:       new(dguard.ProbeInfos),
-		}
-		Probes = append(Probes, &probe)
-		go MonitorProbe(probe)
-	}
+	InitProbesController()
+
+	// Launch disk forecasts
+	InitForecasts()
+
+	// Launch memory leak detector
+	InitMemoryLeakDetector()
+
+	// Reload the config on SIGHUP
+	go WatchConfig()
 
 	// Launch API
 	HTTPServer()
+
+	// Wait for the end
+	WaitShutdown()
 }
 
 /*
-	Loop for monitoring a probe
+	Loop for monitoring a probe (until ctx is canceled)
 */
-func MonitorProbe(p Probe) {
-	var resp *http.Response                         // Http response
-	var req *http.Request                           // Http response
+func MonitorProbe(ctx context.Context, p Probe) {
 	var body []byte                                 // Http body
 	var err error                                   // Error handling
 	var containers map[string]*dguard.Container     // Returned container list
@@ -71,8 +112,27 @@ func MonitorProbe(p Probe) {
 	var dbContainers []dguard.Container             // Containers in DB
 	var tmpProbeInfos dguard.ProbeInfos             // Temporary probe infos
 
-	// Reloading loop
-	for {
+	// Restore the last containers list (if the monitor is restarted)
+	ProbeLastStatsMutex.Lock()
+	for _, c := range ProbeLastStats[p.Name] {
+		var lastContainer = c
+		if containers == nil {
+			containers = make(map[string]*dguard.Container)
+		}
+		containers[c.ID] = &lastContainer
+	}
+	ProbeLastStatsMutex.Unlock()
+
+	// Spread the first reloads of the probes over a reload interval
+	if !sleep(ctx, jitter(p.interval())) {
+		l.Verbose("Probe", p.Name, "monitor stopped")
+		return
+	}
+	var ticker = time.NewTicker(p.interval())
+	defer ticker.Stop()
+
+	// Reloading loop (a reload is skipped if the previous one is too long)
+	for ; ctx.Err() == nil; waitNext(ctx, ticker.C, p.Health) {
 		var statsToInsert []Stat // Stats to insert
 
 		lastContainers = containers
@@ -82,99 +142,68 @@ func MonitorProbe(p Probe) {
 		/*
 			GET PROBE INFOS
 		*/
-		// Make HTTP GET request
-		reqURI := p.URI + "/probeinfos"
-		l.Debug("MonitorProbe: GET", reqURI)
-		req, err = http.NewRequest("GET", reqURI, bytes.NewBufferString(""))
-		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Can't create", p.Name, "HTTP request:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
-			continue
-		}
-		req.Header.Set("Auth", p.APIPassword)
-
-		// Do request
 		l.Debug("MonitorProbe: Get probe infos")
-		resp, err = HTTPClient.Do(req)
+		body, err = getProbe(ctx, &p, "/probeinfos")
 		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Can't get", p.Name, "probe infos:", err)
-			p.Infos.Running = false
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
-			continue
-		}
-		if resp.StatusCode != 200 {
-			l.Error("MonitorProbe ("+p.Name+"): Probe returned a non 200 HTTP status code:", resp.StatusCode)
-			p.Infos.Running = false
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
-			continue
-		}
-
-		// Get request body
-		body, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Can't get", p.Name, "probe infos body:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
+			if ctx.Err() != nil {
+				break
+			}
+			p.setRunning(false)
+			if p.Health.Failed(p.Name, err.Error(), p.interval()) {
+				l.Error("MonitorProbe ("+p.Name+"): Can't get", p.Name, "probe infos:", err)
+			}
 			continue
 		}
 
-		l.Silly("MonitorProbe ("+p.Name+"):", "GET", reqURI, "body:\n", string(body))
-
 		// Parse body
 		err = json.Unmarshal([]byte(body), &(tmpProbeInfos))
 		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Parsing probe infos:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
+			if p.Health.Failed(p.Name, err.Error(), p.interval()) {
+				l.Error("MonitorProbe ("+p.Name+"): Parsing probe infos:", err)
+			}
 			continue
 		}
 		tmpProbeInfos.Running = true
 		tmpProbeInfos.Name = p.Name
-		*(p.Infos) = tmpProbeInfos // Swap probe infos
+		p.setInfos(tmpProbeInfos) // Swap probe infos
 
-		/*
-			GET LIST OF CONTAINERS
-		*/
-		// Make HTTP GET request
-		reqURI = p.URI + "/list"
-		l.Debug("MonitorProbe: GET", reqURI)
-		req, err = http.NewRequest("GET", reqURI, bytes.NewBufferString(""))
-		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Can't create", p.Name, "HTTP request:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
-			continue
-		}
-		req.Header.Set("Auth", p.APIPassword)
+		// Check probe alert rules
+		CheckProbeRules(p.Name, &tmpProbeInfos)
 
-		// Do request
-		l.Debug("MonitorProbe: Get list of containers")
-		resp, err = HTTPClient.Do(req)
+		// Add probe stats in DB
+		err = InsertProbeStat(&tmpProbeInfos)
 		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Can't get", p.Name, "container list:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
-			continue
-		}
-		if resp.StatusCode != 200 {
-			l.Error("MonitorProbe ("+p.Name+"): Probe returned a non 200 HTTP status code:", resp.StatusCode)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
-			continue
+			l.Error("MonitorProbe ("+p.Name+"): insert probe stat:", err)
 		}
 
-		// Get request body
-		body, err = ioutil.ReadAll(resp.Body)
+		/*
+			GET LIST OF CONTAINERS
+		*/
+		l.Debug("MonitorProbe: Get list of containers")
+		body, err = getProbe(ctx, &p, "/list")
 		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Can't get", p.Name, "container list body:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
+			if ctx.Err() != nil {
+				break
+			}
+			if p.Health.Failed(p.Name, err.Error(), p.interval()) {
+				l.Error("MonitorProbe ("+p.Name+"): Can't get", p.Name, "container list:", err)
+			}
 			continue
 		}
 
-		l.Silly("MonitorProbe ("+p.Name+"):", "GET", reqURI, "body:\n", string(body))
-
 		// Parse body
 		err = json.Unmarshal([]byte(body), &containers)
 		if err != nil {
-			l.Error("MonitorProbe ("+p.Name+"): Parsing container list:", err)
-			time.Sleep(time.Second * time.Duration(p.ReloadTime))
+			if p.Health.Failed(p.Name, err.Error(), p.interval()) {
+				l.Error("MonitorProbe ("+p.Name+"): Parsing container list:", err)
+			}
 			continue
 		}
+		p.Health.Succeeded(p.Name)
+
+		// Check if containers were replaced or changed their image
+		// (the replaced containers are not sent as removed and created)
+		imageEvents, replaced := CheckContainerImages(p.Name, containers, containerImageIDs(body))
 
 		// Remove in DB old removed containers
 		l.Debug("MonitorProbe: GetContainersByProbe(", p.Name, ")")
@@ -182,7 +211,6 @@ func MonitorProbe(p Probe) {
 		if err != nil {
 			if err.Error() != "Not found" {
 				l.Error("MonitorProbe ("+p.Name+"): containers not found:", err)
-				time.Sleep(time.Second * time.Duration(p.ReloadTime))
 				continue
 			}
 		}
@@ -213,7 +241,9 @@ func MonitorProbe(p Probe) {
 							Target:   dbC.Hostname + " (" + dbC.ID + ")",
 							Probe:    p.Name,
 							Data:     ""}
-						Alert(event)
+						if RecordStateChange(p.Name, c1) {
+							Alert(event)
+						}
 					}
 				}
 			}
@@ -225,9 +255,16 @@ func MonitorProbe(p Probe) {
 					Probe:    p.Name,
 					Data:     ""}
 
-				DeleteContainer(&dbC)
+				// Alert before deleting the container (watch rules use its metadata)
+				if replaced[dbC.ID] {
+					ResolveContainerAlerts(p.Name, event.Target)
+				} else {
+					Alert(event)
+				}
 
-				Alert(event)
+				DeleteContainer(&dbC)
+				DeleteFlapState(p.Name, dbC.ID)
+				DeleteBaselines(p.Name, dbC.ID)
 			}
 		}
 
@@ -237,22 +274,19 @@ func MonitorProbe(p Probe) {
 			var id string
 			var tmpContainer dguard.Container
 			var newStat Stat
+			var createdEvent *dguard.Event
 
 			// Add containers in DB
 			c.Probe = p.Name
 			tmpContainer, err = GetContainerByCID(c.ID)
 			if err != nil {
 				if err.Error() == "Not found" {
-					var event dguard.Event
-
-					event = dguard.Event{
+					createdEvent = &dguard.Event{
 						Severity: dguard.EventNotice,
 						Type:     dguard.EventContainerCreated,
 						Target:   newContainer.Hostname + " (" + newContainer.ID + ")",
 						Probe:    p.Name,
 						Data:     "Image: " + newContainer.Image}
-
-					Alert(event)
 					id = newContainer.ID
 				} else {
 					l.Error("MonitorProbe ("+p.Name+"): GetContainerById:", err)
@@ -267,6 +301,20 @@ func MonitorProbe(p Probe) {
 				continue
 			}
 
+			// Alert after inserting the container (watch rules use its metadata)
+			if createdEvent != nil && !replaced[c.ID] {
+				Alert(*createdEvent)
+			}
+
+			// Check alert rules
+			CheckContainerRules(p.Name, c)
+
+			// Check if a flapping container is stable
+			CheckFlapping(p.Name, c)
+
+			// Check metrics anomalies
+			CheckAnomalies(p.Name, c)
+
 			newStat = Stat{id,
 				time.Unix(int64(c.Time), 0),
 				float64(c.SizeRootFs),
@@ -279,6 +327,12 @@ func MonitorProbe(p Probe) {
 
 			statsToInsert = append(statsToInsert, newStat)
 		}
+
+		// Alert after inserting the new containers
+		for _, event := range imageEvents {
+			Alert(event)
+		}
+
 		err = InsertStats(statsToInsert, p.Name)
 		if err != nil {
 			l.Error("MonitorProbe ("+p.Name+"): insert stats:", err)
@@ -290,9 +344,91 @@ func MonitorProbe(p Probe) {
 		for _, c := range containers {
 			tmpLastStats = append(tmpLastStats, *c)
 		}
+		ProbeLastStatsMutex.Lock()
 		ProbeLastStats[p.Name] = tmpLastStats
+		ProbeLastStatsMutex.Unlock()
+	}
+	l.Verbose("Probe", p.Name, "monitor stopped")
+}
+
+/*
+	GET a probe API path, within the probe timeout
+*/
+func getProbe(ctx context.Context, p *Probe, path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	// Make HTTP GET request
+	reqURI := p.URI + path
+	l.Debug("MonitorProbe: GET", reqURI)
+	req, err := http.NewRequest("GET", reqURI, nil)
+	if err != nil {
+		return nil, errors.New("Can't create HTTP request: " + err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Auth", p.APIPassword)
+
+	// Do request
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("HTTP status code " + utils.I2S(resp.StatusCode))
+	}
+
+	// Get request body
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New("Can't read body: " + err.Error())
+	}
 
-		// Pause
-		time.Sleep(time.Second * time.Duration(p.ReloadTime))
+	l.Silly("MonitorProbe ("+p.Name+"):", "GET", reqURI, "body:\n", string(body))
+
+	return body, nil
+}
+
+/*
+	Wait for the next reload of a probe monitor (or until ctx is canceled):
+	the next tick, or the next attempt if the probe is failing
+*/
+func waitNext(ctx context.Context, tick <-chan time.Time, health *ProbeHealth) {
+	delay := health.Backoff()
+	if delay <= 0 {
+		select {
+		case <-tick:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	// Drop the ticks missed during the backoff
+	sleep(ctx, delay)
+	select {
+	case <-tick:
+	default:
+	}
+}
+
+/*
+	Return a random duration shorter than d
+*/
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+/*
+	Sleep during d (return false if ctx is canceled before)
+*/
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	var d = time.Second
+	var seen = make(map[time.Duration]bool)
+
+	for i := 0; i < 100; i++ {
+		j := jitter(d)
+		if j < 0 || j >= d {
+			t.Fatalf("jitter(%s) = %s, expected between 0 and %s", d, j, d)
+		}
+		seen[j] = true
+	}
+	if len(seen) < 2 {
+		t.Error("jitter isn't random")
+	}
+	if j := jitter(0); j != 0 {
+		t.Errorf("jitter(0) = %s, expected 0", j)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	var start = time.Now()
+
+	if !sleep(context.Background(), 50*time.Millisecond) {
+		t.Error("sleep interrupted")
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("slept %s, expected 50ms", d)
+	}
+
+	// Canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	if sleep(ctx, time.Minute) {
+		t.Error("sleep not interrupted")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("slept %s after the cancel", d)
+	}
+}
+
+func TestWaitNext(t *testing.T) {
+	var tick = make(chan time.Time, 1)
+	var start = time.Now()
+
+	// Healthy probe: wait for the next tick
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tick <- time.Now()
+	}()
+	waitNext(context.Background(), tick, new(ProbeHealth))
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("waited %s, expected the tick after 50ms", d)
+	}
+
+	// Failing probe: wait for the backoff and drop the missed tick
+	start = time.Now()
+	tick <- time.Now()
+	waitNext(context.Background(), tick, &ProbeHealth{Failures: 1, NextAttempt: start.Add(100 * time.Millisecond)})
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("waited %s, expected the backoff of 100ms", d)
+	}
+	if len(tick) != 0 {
+		t.Error("missed tick not dropped")
+	}
+
+	// Canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	waitNext(ctx, tick, new(ProbeHealth))
+	waitNext(ctx, tick, &ProbeHealth{Failures: 1, NextAttempt: start.Add(time.Minute)})
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("waited %s after the cancel", d)
+	}
+}
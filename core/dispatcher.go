@@ -0,0 +1,242 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"../utils"
+)
+
+const (
+	// File to log undelivered alerts
+	DeadLetterFilePath = "./dead-letters.log"
+
+	// Default dispatcher config
+	DefaultDispatchWorkers   = 4
+	DefaultDispatchQueueSize = 1000
+	DefaultDispatchRetries   = 3
+	DefaultTransportTimeout  = 30 * time.Second
+
+	// Delay before the first retry of an alert (doubled at each retry)
+	DispatchRetryDelay = 2 * time.Second
+)
+
+/*
+	Alert waiting to be sent by a transport
+*/
+type dispatchJob struct {
+	Transport RoutedTransport
+	Message   EventMessage
+	Try       int
+}
+
+/*
+	Undelivered alert
+*/
+type DeadLetter struct {
+	Time      time.Time
+	Transport string
+	Error     string
+	Event     EventMessage
+}
+
+var (
+	// Alerts waiting to be sent
+	dispatchQueue chan dispatchJob
+	// Alerts not sent yet (queued, being sent or waiting for a retry)
+	dispatchPending sync.WaitGroup
+	// True once the dispatcher is stopped (new alerts are dead-lettered)
+	dispatcherClosed bool
+	// dispatcherClosed's Mutex
+	dispatcherClosedMutex sync.Mutex
+	// Alerts waiting for a retry, by retry timer
+	retryJobs = make(map[*time.Timer]dispatchJob)
+	// retryJobs' Mutex
+	retryJobsMutex sync.Mutex
+	// Dead letter file's Mutex
+	deadLetterMutex sync.Mutex
+)
+
+/*
+	Initialize the dispatch queue and launch its workers
+*/
+func InitDispatcher() {
+	var conf = GetConfig().DockerGuard.Event
+	var workers = conf.Workers
+	var queueSize = conf.QueueSize
+
+	if workers <= 0 {
+		workers = DefaultDispatchWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultDispatchQueueSize
+	}
+
+	dispatchQueue = make(chan dispatchJob, queueSize)
+	for i := 0; i < workers; i++ {
+		go dispatchWorker()
+	}
+}
+
+/*
+	Add an alert to the dispatch queue
+	(the alert is dead-lettered if the dispatcher is stopped)
+*/
+func Dispatch(t RoutedTransport, message EventMessage) {
+	var job = dispatchJob{Transport: t, Message: message}
+
+	// Lock / Unlock dispatcherClosed (StopDispatcher must not wait while an
+	// alert is added)
+	dispatcherClosedMutex.Lock()
+	defer dispatcherClosedMutex.Unlock()
+
+	if dispatcherClosed {
+		deadLetter(job, errors.New("dispatcher stopped"))
+		return
+	}
+	dispatchPending.Add(1)
+	enqueue(job)
+}
+
+/*
+	Add a job to the dispatch queue without blocking
+*/
+func enqueue(job dispatchJob) {
+	select {
+	case dispatchQueue <- job:
+	default:
+		deadLetter(job, errors.New("dispatch queue is full"))
+		dispatchPending.Done()
+	}
+}
+
+/*
+	Send the alerts of the dispatch queue
+*/
+func dispatchWorker() {
+	for job := range dispatchQueue {
+		err := sendWithTimeout(job.Transport, job.Message)
+		if err == nil {
+			dispatchPending.Done()
+			continue
+		}
+
+		if job.Try >= job.Transport.MaxRetries() {
+			deadLetter(job, err)
+			dispatchPending.Done()
+			continue
+		}
+
+		// Retry later without blocking the worker
+		delay := DispatchRetryDelay << uint(job.Try)
+		l.Warn("Error transport ("+job.Transport.Name()+"): retry in", delay, "after error:", err)
+		job.Try++
+		retry(job, delay)
+	}
+}
+
+/*
+	Add a job to the dispatch queue after a delay
+	(unless it's dead-lettered by StopDispatcher before)
+*/
+func retry(job dispatchJob, delay time.Duration) {
+	var timer *time.Timer
+
+	// Lock / Unlock retryJobs
+	retryJobsMutex.Lock()
+	defer retryJobsMutex.Unlock()
+
+	timer = time.AfterFunc(delay, func() {
+		retryJobsMutex.Lock()
+		_, ok := retryJobs[timer]
+		delete(retryJobs, timer)
+		retryJobsMutex.Unlock()
+
+		if ok {
+			enqueue(job)
+		}
+	})
+	retryJobs[timer] = job
+}
+
+/*
+	Refuse the new alerts and wait until the pending alerts are sent (or
+	until ctx is done: the alerts waiting for a retry and the queued alerts
+	are then logged in the dead letter file)
+*/
+func StopDispatcher(ctx context.Context) {
+	// Lock / Unlock dispatcherClosed
+	dispatcherClosedMutex.Lock()
+	dispatcherClosed = true
+	dispatcherClosedMutex.Unlock()
+
+	if waitGroup(ctx, &dispatchPending) {
+		return
+	}
+
+	l.Warn("StopDispatcher: alerts not sent before the shutdown timeout")
+	deadLetterPending(errors.New("shutdown"))
+}
+
+/*
+	Log the alerts waiting for a retry and the queued alerts in the dead
+	letter file (the alerts being sent are not changed)
+*/
+func deadLetterPending(err error) {
+	retryJobsMutex.Lock()
+	for timer, job := range retryJobs {
+		timer.Stop()
+		delete(retryJobs, timer)
+		deadLetter(job, err)
+		dispatchPending.Done()
+	}
+	retryJobsMutex.Unlock()
+
+	for {
+		select {
+		case job := <-dispatchQueue:
+			deadLetter(job, err)
+			dispatchPending.Done()
+		default:
+			return
+		}
+	}
+}
+
+/*
+	Send an alert with a transport, within the transport timeout
+*/
+func sendWithTimeout(t RoutedTransport, message EventMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	defer cancel()
+
+	return t.Send(ctx, message)
+}
+
+/*
+	Log an undelivered alert in the dead letter file
+*/
+func deadLetter(job dispatchJob, err error) {
+	l.Error("Error transport ("+job.Transport.Name()+"): alert not delivered:", err)
+
+	tmpJSON, jsonErr := json.Marshal(DeadLetter{
+		Time:      time.Now(),
+		Transport: job.Transport.Name(),
+		Error:     err.Error(),
+		Event:     job.Message,
+	})
+	if jsonErr != nil {
+		l.Error("deadLetter: Failed to marshal struct:", jsonErr)
+		return
+	}
+
+	deadLetterMutex.Lock()
+	jsonErr = utils.FileAppendBytes(DeadLetterFilePath, append(tmpJSON, '\n'))
+	deadLetterMutex.Unlock()
+	if jsonErr != nil {
+		l.Error("deadLetter: Failed to write in file:", jsonErr)
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+func TestDeadLetterPendingRetries(t *testing.T) {
+	server, requests := newTestWebhook(500)
+	defer server.Close()
+
+	rt, err := NewRoutedTransport(TransportConfig{Name: "retried", Type: TransportWebhook, URL: server.URL, Retries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatchQueue == nil {
+		InitDispatcher()
+	}
+
+	// Wait for the first try: the alert then waits for its retry
+	Dispatch(rt, NewEventMessage(dguard.Event{Type: EventProbeRecovered, Target: "probe1", Probe: "probe1"}))
+	for i := 0; len(requests()) < 1 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// On shutdown timeout, the retry is dead-lettered instead of being lost
+	deadLetterPending(errors.New("shutdown"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !waitGroup(ctx, &dispatchPending) {
+		t.Fatal("alert still pending")
+	}
+	content, err := utils.FileReadAllBytes(DeadLetterFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"Transport":"retried","Error":"shutdown"`) {
+		t.Errorf("retry not in the dead letter file:\n%s", content)
+	}
+
+	time.Sleep(DispatchRetryDelay + 500*time.Millisecond)
+	if n := len(requests()); n != 1 {
+		t.Errorf("%d requests received, expected 1 (no retry after the dead letter)", n)
+	}
+}
+
+func TestDispatchAfterStop(t *testing.T) {
+	server, requests := newTestWebhook(200)
+	defer server.Close()
+
+	rt, err := NewRoutedTransport(TransportConfig{Name: "stopped", Type: TransportWebhook, URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatchQueue == nil {
+		InitDispatcher()
+	}
+	defer func() {
+		dispatcherClosedMutex.Lock()
+		dispatcherClosed = false
+		dispatcherClosedMutex.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	StopDispatcher(ctx)
+
+	// The alert is dead-lettered instead of being queued
+	Dispatch(rt, NewEventMessage(dguard.Event{Type: EventProbeRecovered, Target: "probe1", Probe: "probe1"}))
+	if !waitGroup(ctx, &dispatchPending) {
+		t.Fatal("alert pending after the dispatcher is stopped")
+	}
+	content, err := utils.FileReadAllBytes(DeadLetterFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"Transport":"stopped","Error":"dispatcher stopped"`) {
+		t.Errorf("alert not in the dead letter file:\n%s", content)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(requests()); n != 0 {
+		t.Errorf("%d requests received, expected 0", n)
+	}
+}
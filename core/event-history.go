@@ -0,0 +1,361 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+const (
+	EventsMeasurements = "events"
+
+	// Events are written in InfluxDB by batches
+	EventsBatchSize     = 100
+	EventsBatchInterval = time.Second
+	EventsQueueSize     = 1000
+
+	// Default and max number of events returned by GetEvents
+	DefaultEventsLimit = 100
+	MaxEventsLimit     = 10000
+)
+
+/*
+	Filters used to get events
+*/
+type EventFilters struct {
+	Probe     string
+	Container string // Container ID or hostname
+	Type      string
+	Severity  string
+}
+
+var (
+	// Events waiting to be written in InfluxDB
+	eventsToInsert = make(chan EventMessage, EventsQueueSize)
+	// Requests to write the waiting events now (closed when it's done)
+	eventsFlush = make(chan chan bool)
+)
+
+/*
+	Launch the events writer
+*/
+func InitEventHistory() {
+	go eventsWriter()
+}
+
+/*
+	Add an event to the history
+*/
+func InsertEvent(event dguard.Event) {
+	select {
+	case eventsToInsert <- NewEventMessage(event):
+	default:
+		l.Error("InsertEvent: events queue is full, event dropped:", event)
+	}
+}
+
+/*
+	Write the events in InfluxDB by batches
+*/
+func eventsWriter() {
+	var events []EventMessage
+	var ticker = time.NewTicker(EventsBatchInterval)
+
+	for {
+		var flushed chan bool
+
+		select {
+		case e := <-eventsToInsert:
+			events = append(events, e)
+			if len(events) < EventsBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(events) < 1 {
+				continue
+			}
+		case flushed = <-eventsFlush:
+			events = append(events, drainEvents()...)
+		}
+
+		if len(events) > 0 {
+			err := InsertEvents(events)
+			if err != nil {
+				l.Error("eventsWriter: Failed to insert events:", err)
+			}
+			events = nil
+		}
+		if flushed != nil {
+			close(flushed)
+		}
+	}
+}
+
+/*
+	Get the events waiting in the queue without blocking
+*/
+func drainEvents() []EventMessage {
+	var events []EventMessage
+
+	for {
+		select {
+		case e := <-eventsToInsert:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+/*
+	Write the waiting events now
+*/
+func FlushEventHistory(ctx context.Context) error {
+	var flushed = make(chan bool)
+
+	select {
+	case eventsFlush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+	Insert some events
+*/
+func InsertEvents(events []EventMessage) error {
+	var pts = make([]influxdb.Point, len(events)) // InfluxDB points
+	var err error                                 // Error handling
+
+	l.Silly("Insert events:", events)
+	// Make InfluxDB points
+	for i, e := range events {
+		var tags = map[string]string{
+			"probename": e.Probe,
+			"type":      e.Type,
+			"severity":  e.SeverityName,
+		}
+		// InfluxDB doesn't accept empty tags
+		hostname, containerID := splitTarget(e.Target, e.Probe)
+		if hostname != "" {
+			tags["hostname"] = hostname
+		}
+		if containerID != "" {
+			tags["containerid"] = containerID
+		}
+
+		pts[i] = influxdb.Point{
+			Measurement: EventsMeasurements,
+			Tags:        tags,
+			Fields: map[string]interface{}{
+				"target":        e.Target,
+				"data":          e.Data,
+				"severitylevel": e.Severity,
+			},
+			Time:      e.Time,
+			Precision: "us",
+		}
+	}
+
+	// InfluxDB batch points
+	bps := influxdb.BatchPoints{
+		Points:          pts,
+		Database:        GetConfig().DockerGuard.InfluxDB.DB,
+		RetentionPolicy: "default",
+	}
+
+	// Write points in InfluxDB server
+	timer := time.Now()
+	_, err = DB.Write(bps)
+	if err != nil {
+		l.Error("Failed to write in InfluxDB:", bps, ". Error:", err)
+	} else {
+		l.Silly("Events inserted in ", time.Since(timer), ":", bps)
+	}
+
+	return err
+}
+
+/*
+	Split the target "hostname (id)" of a container event
+	(return empty strings for a probe event)
+*/
+func splitTarget(target string, probeName string) (string, string) {
+	if target == probeName {
+		return "", ""
+	}
+	i := strings.LastIndex(target, " (")
+	if i == -1 || !strings.HasSuffix(target, ")") {
+		return target, ""
+	}
+	return target[:i], target[i+2 : len(target)-1]
+}
+
+/*
+	Quote a string in an InfluxDB query
+*/
+func influxQuote(s string) string {
+	return "'" + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `'`, `\'`, -1) + "'"
+}
+
+/*
+	Check the filters and options of an events request
+	(the returned error is a bad request)
+*/
+func CheckEventsRequest(f EventFilters, o Options) error {
+	var err error // Error handling
+
+	if o.Limit > MaxEventsLimit {
+		return errors.New(fmt.Sprintf("limit is to damn high! (%d)", o.Limit))
+	}
+	if o.Since != "" {
+		if _, err = time.Parse(time.RFC3339, o.Since); err != nil {
+			return errors.New("Bad since: " + err.Error())
+		}
+	}
+	if o.Before != "" {
+		if _, err = time.Parse(time.RFC3339, o.Before); err != nil {
+			return errors.New("Bad before: " + err.Error())
+		}
+	}
+	if f.Type != "" {
+		if _, err = EventTypeFromString(f.Type); err != nil {
+			return err
+		}
+	}
+	if f.Severity != "" {
+		if _, err = SeverityFromString(f.Severity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Get events
+*/
+func GetEvents(f EventFilters, o Options) ([]EventMessage, error) {
+	var events []EventMessage // List of events to return
+	var query string          // InfluxDB query
+	var err error             // Error handling
+
+	// Check filters and limitations
+	err = CheckEventsRequest(f, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send query
+	query = eventsQuery(f, o)
+	l.Debug("GetEvents: InfluxDB query:", query)
+	res, err := queryDB(DB, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if empty
+	if len(res) < 1 || len(res[0].Series) < 1 {
+		return events, nil
+	}
+
+	// Get columns indexes
+	var columns = make(map[string]int)
+	for i, c := range res[0].Series[0].Columns {
+		columns[c] = i
+	}
+
+	// Get results
+	for _, row := range res[0].Series[0].Values {
+		var e EventMessage
+
+		e.Time, _ = time.Parse(time.RFC3339, influxString(row, columns, "time"))
+		e.Probe = influxString(row, columns, "probename")
+		e.Type = influxString(row, columns, "type")
+		e.SeverityName = influxString(row, columns, "severity")
+		e.Target = influxString(row, columns, "target")
+		e.Data = influxString(row, columns, "data")
+		if i, ok := columns["severitylevel"]; ok && row[i] != nil {
+			if n, ok := row[i].(json.Number); ok {
+				severity, _ := n.Int64()
+				e.Severity = int(severity)
+			}
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+/*
+	Make the InfluxDB query of a checked events request
+	(without since, it returns the events of the day before "before")
+*/
+func eventsQuery(f EventFilters, o Options) string {
+	var query string // InfluxDB query
+
+	if o.Limit <= 0 {
+		o.Limit = DefaultEventsLimit
+	}
+
+	// Make InfluxDB query
+	query = "SELECT * FROM " + EventsMeasurements + " WHERE time < now()"
+
+	// Add options
+	if o.Since != "" {
+		query += " AND time > " + influxQuote(o.Since)
+	} else if o.Before != "" {
+		before, _ := time.Parse(time.RFC3339, o.Before)
+		query += " AND time > " + influxQuote(before.Add(-24*time.Hour).Format(time.RFC3339Nano))
+	} else {
+		query += " AND time > now() - 1d"
+	}
+	if o.Before != "" {
+		query += " AND time < " + influxQuote(o.Before)
+	}
+
+	// Add filters
+	if f.Probe != "" {
+		query += " AND probename = " + influxQuote(f.Probe)
+	}
+	if f.Container != "" {
+		query += " AND (containerid = " + influxQuote(f.Container) + " OR hostname = " + influxQuote(f.Container) + ")"
+	}
+	if f.Type != "" {
+		query += " AND type = " + influxQuote(f.Type)
+	}
+	if f.Severity != "" {
+		severity, _ := SeverityFromString(f.Severity)
+		query += " AND severity = " + influxQuote(SeverityToString(severity))
+	}
+
+	query += fmt.Sprintf(" ORDER BY time DESC LIMIT %d", o.Limit)
+
+	return query
+}
+
+/*
+	Get a string value of an InfluxDB row
+*/
+func influxString(row []interface{}, columns map[string]int, column string) string {
+	i, ok := columns[column]
+	if !ok || i >= len(row) || row[i] == nil {
+		return ""
+	}
+	s, _ := row[i].(string)
+	return s
+}
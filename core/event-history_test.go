@@ -0,0 +1,67 @@
+package core
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseEventFilters(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events?probe=probe1&container=db-1&type=ProbeRecovered&severity=warning", nil)
+
+	f := ParseEventFilters(r)
+	if f != (EventFilters{Probe: "probe1", Container: "db-1", Type: "ProbeRecovered", Severity: "warning"}) {
+		t.Errorf("filters = %+v", f)
+	}
+}
+
+func TestCheckEventsRequest(t *testing.T) {
+	var tests = []struct {
+		filters EventFilters
+		options Options
+		valid   bool
+	}{
+		{EventFilters{}, Options{}, true},
+		{EventFilters{Type: "ProbeRecovered", Severity: "Critical"}, Options{Since: "2015-09-02T09:27:41Z", Limit: 500}, true},
+		{EventFilters{}, Options{Limit: MaxEventsLimit + 1}, false},
+		{EventFilters{}, Options{Since: "yesterday"}, false},
+		{EventFilters{}, Options{Before: "2015-09-02"}, false},
+		{EventFilters{Type: "Unknown"}, Options{}, false},
+		{EventFilters{Severity: "urgent"}, Options{}, false},
+	}
+
+	for _, test := range tests {
+		err := CheckEventsRequest(test.filters, test.options)
+		if (err == nil) != test.valid {
+			t.Errorf("CheckEventsRequest(%+v, %+v) = %v, expected valid: %t", test.filters, test.options, err, test.valid)
+		}
+	}
+}
+
+func TestEventsQuery(t *testing.T) {
+	var tests = []struct {
+		filters  EventFilters
+		options  Options
+		contains []string
+	}{
+		// The last day by default
+		{EventFilters{}, Options{},
+			[]string{"time > now() - 1d", "LIMIT 100"}},
+		// The day before "before" without since
+		{EventFilters{}, Options{Before: "2015-09-02T09:27:41Z"},
+			[]string{"time > '2015-09-01T09:27:41Z'", "time < '2015-09-02T09:27:41Z'"}},
+		{EventFilters{}, Options{Since: "2015-08-01T00:00:00Z", Before: "2015-09-02T09:27:41Z", Limit: 5},
+			[]string{"time > '2015-08-01T00:00:00Z'", "time < '2015-09-02T09:27:41Z'", "LIMIT 5"}},
+		{EventFilters{Probe: "probe'1", Container: "db-1", Severity: "critical"}, Options{},
+			[]string{`probename = 'probe\'1'`, "containerid = 'db-1' OR hostname = 'db-1'", "severity = 'Critical'"}},
+	}
+
+	for _, test := range tests {
+		query := eventsQuery(test.filters, test.options)
+		for _, s := range test.contains {
+			if !strings.Contains(query, s) {
+				t.Errorf("query %q doesn't contain %q", query, s)
+			}
+		}
+	}
+}
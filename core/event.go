@@ -1,62 +1,227 @@
 package core
 
 import (
-	"os/exec"
-	"regexp"
-
-	"../utils"
+	"errors"
+	"strings"
 
 	dguard "github.com/90TechSAS/libgo-docker-guard"
 )
 
-var ()
+/*
+	Event types raised by Docker Guard Monitoring
+	(in addition to libgo-docker-guard's event types)
+*/
+const (
+	EventAlertResolved = iota + 100
+	EventProbeUnreachable
+	EventProbeRecovered
+	EventContainerFlapping
+	EventContainerReplaced
+	EventContainerImageChanged
+	EventMetricAnomaly
+	EventMemoryLeakSuspected
+)
+
+var (
+	// Names of Docker Guard Monitoring's event types
+	eventTypeNames = map[int]string{
+		EventAlertResolved:         "AlertResolved",
+		EventProbeUnreachable:      "ProbeUnreachable",
+		EventProbeRecovered:        "ProbeRecovered",
+		EventContainerFlapping:     "ContainerFlapping",
+		EventContainerReplaced:     "ContainerReplaced",
+		EventContainerImageChanged: "ContainerImageChanged",
+		EventMetricAnomaly:         "MetricAnomaly",
+		EventMemoryLeakSuspected:   "MemoryLeakSuspected",
+	}
+	// libgo-docker-guard's event types
+	dguardEventTypes = []int{
+		dguard.EventDiskSpaceLimitReached,
+		dguard.EventMemorySpaceLimitReached,
+		dguard.EventContainerStarted,
+		dguard.EventContainerStopped,
+		dguard.EventContainerCreated,
+		dguard.EventContainerRemoved,
+		dguard.EventNetBandwithOverload,
+		dguard.EventCPUUsageOverload,
+	}
+)
 
 /*
-	Transport struct
+	Return the name of a severity level
+*/
+func SeverityToString(severity int) string {
+	switch severity {
+	case dguard.EventNotice:
+		return "Notice"
+	case dguard.EventWarning:
+		return "Warning"
+	case dguard.EventCritical:
+		return "Critical"
+	}
+	return "Unknown"
+}
 
-	path is the path to the transport program
+/*
+	Return the severity level of a name (case insensitive)
 */
-type Transport struct {
-	Name string `yaml:"name"`
-	Path string `yaml:"path"`
+func SeverityFromString(name string) (int, error) {
+	for _, severity := range []int{dguard.EventNotice, dguard.EventWarning, dguard.EventCritical} {
+		if strings.EqualFold(name, SeverityToString(severity)) {
+			return severity, nil
+		}
+	}
+	return 0, errors.New("Unknown severity: " + name)
 }
 
 /*
-	Send an event alert with corresponding transport(s)
+	Return the event type of a name
 */
-func Alert(event dguard.Event) {
-	var err error     // Error handling
-	var out []byte    // Command output
-	var alert = false // true if the alert needs to be sent
+func EventTypeFromString(name string) (int, error) {
+	for t, n := range eventTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	for _, t := range dguardEventTypes {
+		if EventTypeToString(dguard.Event{Type: t}) == name {
+			return t, nil
+		}
+	}
+	return 0, errors.New("Unknown event type: " + name)
+}
+
+/*
+	Return the name of an event type
+*/
+func EventTypeToString(event dguard.Event) string {
+	if name, ok := eventTypeNames[event.Type]; ok {
+		return name
+	}
+	return event.TypeToString()
+}
 
-	for _, rgxp := range DGConfig.DockerGuard.Event.Watch {
-		r, err := regexp.MatchString(rgxp, event.Target)
-		if err != nil {
-			l.Error("Error processing regexp:", err)
-			return
+/*
+	Check if an event's target is watched
+	(events targeting a probe are always watched)
+
+	A container is watched if it doesn't match an ignore rule, and its target
+	"hostname (id)" matches a watch regexp or it matches a watch rule.
+*/
+func Watched(event dguard.Event) bool {
+	var conf = GetConfig().DockerGuard.Event
+
+	if event.Target == event.Probe {
+		return true
+	}
+
+	if len(conf.WatchRules) > 0 || len(conf.IgnoreRules) > 0 {
+		c := eventContainer(event)
+		if matchWatchRules(conf.IgnoreRules, c) {
+			return false
 		}
-		if r {
-			alert = true
-			break
+		if matchWatchRules(conf.WatchRules, c) {
+			return true
 		}
 	}
 
-	if !alert {
+	for _, rgxp := range conf.watch {
+		if rgxp.MatchString(event.Target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Send the resolved event of a firing alert
+*/
+func ResolveAlert(event dguard.Event) {
+	ResolveAlertFrom("", event)
+}
+
+/*
+	Send the resolved event of a firing alert raised by a source
+*/
+func ResolveAlertFrom(source string, event dguard.Event) {
+	if !Watched(event) {
 		return
 	}
 
-	// Exec transports
-	for _, t := range DGConfig.DockerGuard.Event.Transports {
-		out, err = exec.Command(t.Path,
-			utils.I2S(event.Severity),
-			event.TypeToString(),
-			event.Target,
-			event.Probe,
-			event.Data).Output()
-		if err != nil {
-			l.Error("Error transport ("+t.Name+") Err: ", err, "/ Out:", string(out))
-			return
+	// Check if the alert is firing
+	firingEvent, ok := ResolveAlertState(source, event)
+	if !ok {
+		return
+	}
+
+	resolvedEvent := dguard.Event{
+		Severity: dguard.EventNotice,
+		Type:     EventAlertResolved,
+		Target:   event.Target,
+		Probe:    event.Probe,
+		Data:     EventTypeToString(event) + " resolved: " + event.Data}
+	InsertEvent(resolvedEvent)
+
+	// The resolved event is routed like the firing event
+	if Silenced(firingEvent) {
+		l.Debug("Alert silenced:", EventTypeToString(resolvedEvent), event.Target, "("+event.Probe+")")
+		return
+	}
+	sendAlert(resolvedEvent, firingEvent)
+}
+
+/*
+	Send an event alert with corresponding transport(s)
+*/
+func Alert(event dguard.Event) {
+	AlertFrom("", event)
+}
+
+/*
+	Send an event alert raised by a source (its state is kept apart from the
+	same event raised by other sources)
+*/
+func AlertFrom(source string, event dguard.Event) {
+	// Apply the severity rules (dropped events are not recorded)
+	event.Severity = EventSeverity(event)
+	if event.Severity == SeverityDrop {
+		l.Debug("Alert dropped:", EventTypeToString(event), event.Target, "("+event.Probe+")")
+		return
+	}
+
+	// Add the event to the history (even if it's not sent)
+	InsertEvent(event)
+
+	if !Watched(event) {
+		return
+	}
+
+	// Check if the alert is silenced or was already sent
+	silenced := Silenced(event)
+	if !UpdateAlertState(source, event, silenced) {
+		if silenced {
+			l.Debug("Alert silenced:", EventTypeToString(event), event.Target, "("+event.Probe+")")
+		} else {
+			l.Debug("Alert already sent:", EventTypeToString(event), event.Target, "("+event.Probe+")")
+		}
+		return
+	}
+
+	sendAlert(event, event)
+}
+
+/*
+	Dispatch an event to the transports routing the route event
+	(its message is made once, so every transport gets the same time)
+*/
+func sendAlert(event dguard.Event, route dguard.Event) {
+	var message = NewEventMessage(event)
+
+	for _, t := range GetTransports() {
+		if !t.Filter.Match(route) {
+			continue
 		}
-		l.Debug("Transport ("+t.Name+") Out:", string(out))
+		Dispatch(t, message)
 	}
 }
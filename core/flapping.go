@@ -0,0 +1,191 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+const (
+	// Default number of state changes in the window for a flapping container
+	DefaultFlapChanges = 5
+	// Default flapping window
+	DefaultFlapWindow = 10 * time.Minute
+)
+
+/*
+	Flapping state of a container
+
+	Changes are the times of the state changes (started / stopped) during the
+	flapping window. While Flapping, the ContainerStarted and ContainerStopped
+	events are not sent, Running is the state of the container in the last
+	event sent.
+*/
+type flapState struct {
+	Changes  []time.Time
+	Flapping bool
+	Running  bool
+}
+
+var (
+	// map[PROBE_NAME|CONTAINER_ID] => flapState
+	flapStates = make(map[string]*flapState)
+	// flapStates's Mutex
+	flapStatesMutex sync.Mutex
+)
+
+/*
+	Record a state change of a container, and send a ContainerFlapping event
+	when it changes too often
+	(return false if the ContainerStarted / ContainerStopped event must not
+	be sent)
+*/
+func RecordStateChange(probeName string, c *dguard.Container) bool {
+	var conf = GetConfig().DockerGuard.Event
+	var maxChanges = conf.FlapChanges
+	var window = conf.flapWindow
+	var now = time.Now()
+	var event dguard.Event
+
+	if maxChanges < 0 {
+		return true
+	}
+	if maxChanges == 0 {
+		maxChanges = DefaultFlapChanges
+	}
+
+	// Lock / Unlock flapStates
+	flapStatesMutex.Lock()
+	s, ok := flapStates[probeName+"|"+c.ID]
+	if !ok {
+		s = restoredFlapState(probeName, c, !c.Running, now)
+		if s == nil {
+			s = new(flapState)
+		}
+		flapStates[probeName+"|"+c.ID] = s
+	}
+	s.Changes = append(pruneChanges(s.Changes, now.Add(-window)), now)
+	if s.Flapping {
+		flapStatesMutex.Unlock()
+		return false
+	}
+	if len(s.Changes) <= maxChanges {
+		flapStatesMutex.Unlock()
+		return true
+	}
+	s.Flapping = true
+	s.Running = !c.Running
+	event = dguard.Event{
+		Severity: dguard.EventWarning,
+		Type:     EventContainerFlapping,
+		Target:   c.Hostname + " (" + c.ID + ")",
+		Probe:    probeName,
+		Data:     utils.I2S(len(s.Changes)) + " state changes in " + window.String()}
+	flapStatesMutex.Unlock()
+
+	l.Warn("Container", event.Target, "("+probeName+") is flapping")
+	Alert(event)
+	return false
+}
+
+/*
+	Check if a flapping container is stable again (no state change during
+	the flapping window): resolve the ContainerFlapping alert and send its
+	current state if it changed
+*/
+func CheckFlapping(probeName string, c *dguard.Container) {
+	var window = GetConfig().DockerGuard.Event.flapWindow
+	var now = time.Now()
+	var event dguard.Event
+
+	// Lock / Unlock flapStates
+	flapStatesMutex.Lock()
+	s, ok := flapStates[probeName+"|"+c.ID]
+	if !ok {
+		// Wait for a whole window to resolve an alert fired before a restart
+		if s = restoredFlapState(probeName, c, c.Running, now); s != nil {
+			flapStates[probeName+"|"+c.ID] = s
+		}
+		flapStatesMutex.Unlock()
+		return
+	}
+	s.Changes = pruneChanges(s.Changes, now.Add(-window))
+	if len(s.Changes) > 0 {
+		flapStatesMutex.Unlock()
+		return
+	}
+	delete(flapStates, probeName+"|"+c.ID)
+	flapStatesMutex.Unlock()
+	if !s.Flapping {
+		return
+	}
+
+	event = dguard.Event{
+		Severity: dguard.EventWarning,
+		Type:     EventContainerFlapping,
+		Target:   c.Hostname + " (" + c.ID + ")",
+		Probe:    probeName,
+		Data:     "Stable for " + window.String()}
+	l.Info("Container", event.Target, "("+probeName+") is stable")
+	ResolveAlert(event)
+
+	if c.Running == s.Running {
+		return
+	}
+	event.Data = ""
+	if c.Running {
+		event.Severity = dguard.EventNotice
+		event.Type = dguard.EventContainerStarted
+	} else {
+		event.Severity = dguard.EventCritical
+		event.Type = dguard.EventContainerStopped
+	}
+	Alert(event)
+}
+
+/*
+	Make the flapping state of a container whose ContainerFlapping alert is
+	still firing (the flapping states are lost on restart, the alerts are
+	not), or return nil
+	(running is the state of the container in the last event sent)
+*/
+func restoredFlapState(probeName string, c *dguard.Container, running bool, now time.Time) *flapState {
+	var event = dguard.Event{
+		Type:   EventContainerFlapping,
+		Target: c.Hostname + " (" + c.ID + ")",
+		Probe:  probeName}
+
+	a, ok := GetAlertState("", event)
+	if !ok || a.Status != AlertFiring {
+		return nil
+	}
+	return &flapState{
+		Changes:  []time.Time{now},
+		Flapping: true,
+		Running:  running}
+}
+
+/*
+	Forget the flapping state of a removed container
+*/
+func DeleteFlapState(probeName string, containerID string) {
+	// Lock / Unlock flapStates
+	flapStatesMutex.Lock()
+	defer flapStatesMutex.Unlock()
+
+	delete(flapStates, probeName+"|"+containerID)
+}
+
+/*
+	Remove the state changes older than a date
+*/
+func pruneChanges(changes []time.Time, since time.Time) []time.Time {
+	var i int
+	for i < len(changes) && changes[i].Before(since) {
+		i++
+	}
+	return changes[i:]
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestFlapping(t *testing.T) {
+	var c = dguard.Container{ID: "abc123", Hostname: "web-1", Running: true}
+	var event = dguard.Event{Type: EventContainerFlapping, Target: "web-1 (abc123)", Probe: "probe1"}
+	var window = 100 * time.Millisecond
+
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+		c.DockerGuard.Event.FlapChanges = 2
+		c.DockerGuard.Event.flapWindow = window
+	})()
+	alertList = make(map[string]*AlertState)
+	defer DeleteFlapState("probe1", c.ID)
+
+	// Up to FlapChanges changes are sent
+	for i := 0; i < 2; i++ {
+		c.Running = !c.Running
+		if !RecordStateChange("probe1", &c) {
+			t.Fatalf("change %d not sent", i)
+		}
+	}
+	if _, ok := GetAlertState("", event); ok {
+		t.Fatal("flapping before FlapChanges changes")
+	}
+
+	// The next ones aren't sent while flapping
+	for i := 0; i < 3; i++ {
+		c.Running = !c.Running
+		if RecordStateChange("probe1", &c) {
+			t.Fatalf("change %d sent while flapping", i)
+		}
+		if a, ok := GetAlertState("", event); !ok || a.Status != AlertFiring {
+			t.Fatalf("change %d: flapping not firing", i)
+		}
+	}
+
+	// Resolved after a whole window without change
+	CheckFlapping("probe1", &c)
+	if a, _ := GetAlertState("", event); a.Status != AlertFiring {
+		t.Fatalf("flapping %s before the end of the window", a.Status)
+	}
+	time.Sleep(window + 20*time.Millisecond)
+	CheckFlapping("probe1", &c)
+	if a, _ := GetAlertState("", event); a.Status != AlertResolved {
+		t.Fatalf("flapping %s, expected resolved", a.Status)
+	}
+	if !RecordStateChange("probe1", &c) {
+		t.Fatal("change not sent after the container is stable")
+	}
+}
+
+func TestFlappingRestart(t *testing.T) {
+	var c = dguard.Container{ID: "abc123", Hostname: "web-1", Running: true}
+	var event = dguard.Event{Severity: dguard.EventWarning, Type: EventContainerFlapping, Target: "web-1 (abc123)", Probe: "probe1"}
+	var window = 100 * time.Millisecond
+
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+		c.DockerGuard.Event.FlapChanges = 2
+		c.DockerGuard.Event.flapWindow = window
+	})()
+	alertList = make(map[string]*AlertState)
+	defer DeleteFlapState("probe1", c.ID)
+
+	// Alert persisted before a restart, without flapping state
+	Alert(event)
+	if a, ok := GetAlertState("", event); !ok || a.Status != AlertFiring {
+		t.Fatal("flapping not firing")
+	}
+
+	// Still flapping: the state changes aren't sent
+	c.Running = false
+	if RecordStateChange("probe1", &c) {
+		t.Fatal("change sent while the persisted alert is firing")
+	}
+	DeleteFlapState("probe1", c.ID)
+
+	// Stable: resolved after a whole window
+	CheckFlapping("probe1", &c)
+	if a, _ := GetAlertState("", event); a.Status != AlertFiring {
+		t.Fatalf("flapping %s before the end of the window", a.Status)
+	}
+	time.Sleep(window + 20*time.Millisecond)
+	CheckFlapping("probe1", &c)
+	if a, _ := GetAlertState("", event); a.Status != AlertResolved {
+		t.Fatalf("flapping %s, expected resolved", a.Status)
+	}
+}
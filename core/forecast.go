@@ -0,0 +1,320 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+const (
+	// Default history used by the forecasts
+	DefaultForecastWindow = 6 * time.Hour
+	// Default time-to-full under which an alert is sent
+	DefaultForecastHorizon = 24 * time.Hour
+	// Default interval between two forecasts of every disk
+	DefaultForecastInterval = 5 * time.Minute
+	// Min number of samples of a forecast
+	ForecastMinSamples = 3
+	// Forecasts are not dated beyond this duration
+	MaxForecastDuration = 100 * 365 * 24 * time.Hour
+	// Prefix of the data of forecast alerts
+	ForecastAlertPrefix = "Forecast: "
+	// Source of the forecast alerts states
+	ForecastAlertSource = "forecast"
+)
+
+/*
+	Disk forecast config
+
+	The disk usage of the last Window is used to forecast when the disk of
+	each probe will be full. If Enabled, the forecasts are done every
+	Interval and a DiskSpaceLimitReached warning is sent when a disk will be
+	full in less than Horizon.
+*/
+type ForecastConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Window   string `yaml:"window"`
+	Horizon  string `yaml:"horizon"`
+	Interval string `yaml:"interval"`
+	window   time.Duration
+	horizon  time.Duration
+	interval time.Duration
+}
+
+/*
+	Disk forecast
+
+	Slope is the growth of the used space in bytes per second (computed by
+	linear regression), Available the available space of the probe disk in
+	bytes. TimeToFull (in seconds) is only set if the disk is filling up,
+	and FullAt if it will be full in less than a century.
+*/
+type Forecast struct {
+	Target     string
+	Probe      string
+	Metric     string
+	Samples    int
+	Slope      float64
+	Available  float64
+	TimeToFull float64
+	FullAt     *time.Time
+}
+
+/*
+	Check the forecast config and set the default values
+*/
+func (c *ForecastConfig) Check() error {
+	var err error // Error handling
+
+	for _, d := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+		def      time.Duration
+	}{
+		{"window", c.Window, &c.window, DefaultForecastWindow},
+		{"horizon", c.Horizon, &c.horizon, DefaultForecastHorizon},
+		{"interval", c.Interval, &c.interval, DefaultForecastInterval},
+	} {
+		*d.duration = d.def
+		if d.value == "" {
+			continue
+		}
+		*d.duration, err = time.ParseDuration(d.value)
+		if err != nil {
+			return errors.New("Bad " + d.name + ": " + err.Error())
+		}
+		if *d.duration <= 0 {
+			return errors.New("Bad " + d.name + ": must be positive")
+		}
+	}
+
+	return nil
+}
+
+/*
+	Launch the forecasts loop
+*/
+func InitForecasts() {
+	go forecastLoop()
+}
+
+/*
+	Forecast the disks of the probes and containers, and send alerts
+	(the config is read at each iteration, so it can be reloaded)
+*/
+func forecastLoop() {
+	for {
+		select {
+		case <-time.After(GetConfig().DockerGuard.Forecast.interval):
+		case <-shutdownCtx.Done():
+			return
+		}
+		if !GetConfig().DockerGuard.Forecast.Enabled {
+			continue
+		}
+
+		for _, p := range GetProbes() {
+			f, err := ForecastProbe(p.Name)
+			if err != nil {
+				l.Debug("forecastLoop: Can't forecast probe", p.Name+":", err)
+				continue
+			}
+			checkForecast(f)
+
+			containers, err := GetContainersByProbe(p.Name)
+			if err != nil {
+				continue
+			}
+			for _, c := range containers {
+				f, err = ForecastContainer(c.ID)
+				if err != nil {
+					l.Debug("forecastLoop: Can't forecast container", c.ID+":", err)
+					continue
+				}
+				checkForecast(f)
+			}
+		}
+	}
+}
+
+/*
+	Send a DiskSpaceLimitReached warning if the disk will be full before the
+	horizon, or resolve it
+	(forecast alerts have their own state, apart from the rules alerts)
+*/
+func checkForecast(f Forecast) {
+	var event = dguard.Event{
+		Severity: dguard.EventWarning,
+		Type:     dguard.EventDiskSpaceLimitReached,
+		Target:   f.Target,
+		Probe:    f.Probe}
+
+	if f.FullAt != nil && f.TimeToFull < GetConfig().DockerGuard.Forecast.horizon.Seconds() {
+		event.Data = ForecastAlertPrefix + f.Metric + " full in " +
+			(time.Duration(f.TimeToFull) * time.Second).String() + " (" + f.FullAt.Format(time.RFC3339) + ")"
+		AlertFrom(ForecastAlertSource, event)
+	} else if a, ok := GetAlertState(ForecastAlertSource, event); ok && a.Status == AlertFiring {
+		ResolveAlertFrom(ForecastAlertSource, event)
+	}
+}
+
+/*
+	Forecast when the disk of a probe will be full
+*/
+func ForecastProbe(probeName string) (Forecast, error) {
+	var f = Forecast{
+		Target: probeName,
+		Probe:  probeName,
+		Metric: MetricDiskAvailable,
+	}
+
+	query := "SELECT diskavailable FROM " + ProbeStatsMeasurements +
+		" WHERE probename = " + influxQuote(probeName) + sinceWindow()
+	samples, err := querySamples(query)
+	if err != nil {
+		return f, err
+	}
+
+	// The used space grows when the available space decreases
+	slope := linearRegression(samples)
+	f.Samples = len(samples)
+	f.Slope = -slope
+	f.Available = samples[len(samples)-1][1]
+	f.setTimeToFull()
+
+	return f, nil
+}
+
+/*
+	Forecast when the writable layer of a container will fill the disk of
+	its probe
+*/
+func ForecastContainer(containerCID string) (Forecast, error) {
+	var f Forecast
+
+	c, err := GetContainerByCID(containerCID)
+	if err != nil {
+		return f, err
+	}
+	f = Forecast{
+		Target: c.Hostname + " (" + c.ID + ")",
+		Probe:  c.Probe,
+		Metric: MetricSizeRw,
+	}
+
+	infos, err := GetProbeInfos(c.Probe)
+	if err != nil {
+		return f, err
+	}
+	if !infos.Running {
+		return f, errors.New("Probe " + c.Probe + " isn't running")
+	}
+
+	query := "SELECT sizerw FROM " + StatsMeasurements +
+		" WHERE containerid = " + influxQuote(c.ID) + sinceWindow()
+	samples, err := querySamples(query)
+	if err != nil {
+		return f, err
+	}
+
+	f.Samples = len(samples)
+	f.Slope = linearRegression(samples)
+	f.Available = float64(infos.DiskAvailable)
+	f.setTimeToFull()
+
+	return f, nil
+}
+
+/*
+	Set the time-to-full of a forecast
+*/
+func (f *Forecast) setTimeToFull() {
+	if f.Slope <= 0 {
+		return
+	}
+	f.TimeToFull = f.Available / f.Slope
+	if f.TimeToFull >= MaxForecastDuration.Seconds() {
+		return
+	}
+	fullAt := time.Now().Add(time.Duration(f.TimeToFull) * time.Second)
+	f.FullAt = &fullAt
+}
+
+/*
+	Return the time condition of the forecast window in an InfluxDB query
+*/
+func sinceWindow() string {
+	return fmt.Sprintf(" AND time > now() - %ds", int(GetConfig().DockerGuard.Forecast.window.Seconds()))
+}
+
+/*
+	Get the samples (unix time, value) of the first field returned by a query
+*/
+func querySamples(query string) ([][2]float64, error) {
+	var samples [][2]float64 // Samples to return
+
+	// Send query
+	l.Debug("querySamples: InfluxDB query:", query)
+	res, err := queryDB(DB, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) < 1 || len(res[0].Series) < 1 {
+		return nil, errors.New("Not found")
+	}
+
+	for _, row := range res[0].Series[0].Values {
+		if len(row) < 2 || row[1] == nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fmt.Sprint(row[0]))
+		if err != nil {
+			continue
+		}
+		n, ok := row[1].(json.Number)
+		if !ok {
+			continue
+		}
+		value, err := n.Float64()
+		if err != nil {
+			continue
+		}
+		samples = append(samples, [2]float64{float64(t.UnixNano()) / float64(time.Second), value})
+	}
+	if len(samples) < ForecastMinSamples {
+		return nil, errors.New(fmt.Sprintf("Not enough samples (%d)", len(samples)))
+	}
+
+	return samples, nil
+}
+
+/*
+	Return the slope of the least squares line of some samples (x, y)
+*/
+func linearRegression(samples [][2]float64) float64 {
+	var sumX, sumY, sumXY, sumXX float64
+	var n = float64(len(samples))
+
+	if len(samples) < 2 {
+		return 0
+	}
+
+	// x is relative to the first sample to keep the precision
+	for _, s := range samples {
+		x := s[0] - samples[0][0]
+		sumX += x
+		sumY += s[1]
+		sumXY += x * s[1]
+		sumXX += x * x
+	}
+
+	d := n*sumXX - sumX*sumX
+	if d == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / d
+}
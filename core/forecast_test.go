@@ -0,0 +1,92 @@
+package core
+
+import (
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestLinearRegression(t *testing.T) {
+	var start = float64(time.Now().Unix())
+	var tests = []struct {
+		name    string
+		samples [][2]float64
+		slope   float64
+	}{
+		{"no sample", nil, 0},
+		{"one sample", [][2]float64{{start, 10}}, 0},
+		{"flat", [][2]float64{{start, 10}, {start + 60, 10}, {start + 120, 10}}, 0},
+		{"growing", [][2]float64{{start, 5}, {start + 1, 7}, {start + 2, 9}, {start + 3, 11}}, 2},
+		{"decreasing", [][2]float64{{start, 100}, {start + 10, 90}, {start + 20, 80}}, -1},
+		{"noisy", [][2]float64{{start, 1}, {start + 1, 3}, {start + 2, 2}, {start + 3, 4}}, 0.8},
+		{"same time", [][2]float64{{start, 1}, {start, 5}}, 0},
+	}
+
+	for _, test := range tests {
+		if slope := linearRegression(test.samples); math.Abs(slope-test.slope) > 1e-9 {
+			t.Errorf("%s: slope %v, expected %v", test.name, slope, test.slope)
+		}
+	}
+}
+
+func TestForecastTimeToFull(t *testing.T) {
+	var f = Forecast{Slope: 10, Available: 3600}
+
+	f.setTimeToFull()
+	if f.TimeToFull != 360 || f.FullAt == nil {
+		t.Errorf("time to full %v (full at %v), expected 360", f.TimeToFull, f.FullAt)
+	}
+
+	// Not filling up, or full in more than a century
+	for _, slope := range []float64{0, -10, 1e-10} {
+		f = Forecast{Slope: slope, Available: 3600}
+		f.setTimeToFull()
+		if f.FullAt != nil {
+			t.Errorf("slope %v: full at %v, expected never", slope, f.FullAt)
+		}
+	}
+}
+
+func TestCheckForecast(t *testing.T) {
+	var event = dguard.Event{Type: dguard.EventDiskSpaceLimitReached, Target: "probe1", Probe: "probe1"}
+	var conf = ForecastConfig{Horizon: "24h"}
+	err := conf.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+		c.DockerGuard.Forecast = conf
+	})()
+	alertList = make(map[string]*AlertState)
+
+	// Full in more than the horizon
+	var f = Forecast{Target: "probe1", Probe: "probe1", Metric: MetricDiskAvailable, Slope: 1, Available: 48 * 3600}
+	f.setTimeToFull()
+	checkForecast(f)
+	if _, ok := GetAlertState(ForecastAlertSource, event); ok {
+		t.Fatal("alert sent for a disk full after the horizon")
+	}
+
+	// Full before the horizon
+	f.Available = 12 * 3600
+	f.setTimeToFull()
+	checkForecast(f)
+	if a, ok := GetAlertState(ForecastAlertSource, event); !ok || a.Status != AlertFiring {
+		t.Fatal("forecast alert not firing")
+	}
+	if _, ok := GetAlertState("", event); ok {
+		t.Fatal("forecast alert sent without its source")
+	}
+
+	// Not filling up anymore
+	f = Forecast{Target: "probe1", Probe: "probe1", Metric: MetricDiskAvailable, Slope: -1, Available: 12 * 3600}
+	f.setTimeToFull()
+	checkForecast(f)
+	if a, _ := GetAlertState(ForecastAlertSource, event); a.Status != AlertResolved {
+		t.Errorf("forecast alert %s, expected resolved", a.Status)
+	}
+}
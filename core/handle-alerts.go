@@ -0,0 +1,262 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// Max size of a POST body
+	MaxBodySize = 1 << 20
+)
+
+/*
+	Alert acknowledgement request
+*/
+type ackRequest struct {
+	Type    string
+	Probe   string
+	Target  string
+	Source  string
+	Comment string
+}
+
+/*
+	Silence request
+
+	Start and End are RFC3339 dates, Start is now by default and Duration
+	("30m", "2h", ...) can be used instead of End.
+*/
+type silenceRequest struct {
+	Probe     string
+	Container string
+	Type      string
+	Start     string
+	End       string
+	Duration  string
+	Comment   string
+}
+
+/*
+	Return alerts states
+*/
+func HTTPHandlerAlerts(w http.ResponseWriter, r *http.Request) {
+	var returnStr string            // HTTP Response body
+	var returnedAlerts []AlertState // Returned alerts
+	var status string               // HTTP GET parameter
+	var acknowledged string         // HTTP GET parameter
+
+	status = r.URL.Query().Get("status")
+	acknowledged = r.URL.Query().Get("acknowledged")
+	if status != "" && status != AlertPending && status != AlertFiring && status != AlertResolved {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+	if acknowledged != "" && acknowledged != "true" && acknowledged != "false" {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+
+	// Get alerts
+	for _, a := range GetAlertStates(status) {
+		if acknowledged != "" && a.Acknowledged != (acknowledged == "true") {
+			continue
+		}
+		returnedAlerts = append(returnedAlerts, a)
+	}
+
+	// returnedAlerts => json
+	tmpJSON, err := json.Marshal(returnedAlerts)
+	if err != nil {
+		l.Error("HTTPHandlerAlerts: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// Add json to the returned string
+	returnStr = string(tmpJSON)
+	if returnStr == "null" {
+		returnStr = "[]"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	fmt.Fprint(w, returnStr)
+}
+
+/*
+	Acknowledge a firing alert
+*/
+func HTTPHandlerAlertsAck(w http.ResponseWriter, r *http.Request) {
+	var returnStr string   // HTTP Response body
+	var request ackRequest // Request body
+	var alert AlertState   // Acknowledged alert
+	var err error          // Error handling
+
+	// Parse body
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize)).Decode(&request)
+	if err != nil {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+	eventType, err := EventTypeFromString(request.Type)
+	if err != nil {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+
+	// Acknowledge alert
+	user, _, _ := r.BasicAuth()
+	alert, err = AcknowledgeAlert(eventType, request.Probe, request.Target, request.Source, user, request.Comment)
+	if err != nil {
+		if strings.Contains(err.Error(), "Not found") {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		l.Error("HTTPHandlerAlertsAck: Failed to acknowledge alert:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+	l.Info("Alert", request.Type, request.Target, "("+request.Probe+") acknowledged by", user)
+
+	// alert => json
+	tmpJSON, err := json.Marshal(alert)
+	if err != nil {
+		l.Error("HTTPHandlerAlertsAck: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	returnStr = string(tmpJSON)
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	fmt.Fprint(w, returnStr)
+}
+
+/*
+	Return active and future silences
+*/
+func HTTPHandlerSilences(w http.ResponseWriter, r *http.Request) {
+	var returnStr string // HTTP Response body
+
+	// silences => json
+	tmpJSON, err := json.Marshal(GetSilences())
+	if err != nil {
+		l.Error("HTTPHandlerSilences: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// Add json to the returned string
+	returnStr = string(tmpJSON)
+	if returnStr == "null" {
+		returnStr = "[]"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	fmt.Fprint(w, returnStr)
+}
+
+/*
+	Create a silence
+*/
+func HTTPHandlerSilencesCreate(w http.ResponseWriter, r *http.Request) {
+	var returnStr string       // HTTP Response body
+	var request silenceRequest // Request body
+	var silence Silence        // Created silence
+	var err error              // Error handling
+
+	// Parse body
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize)).Decode(&request)
+	if err != nil {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+
+	// Make silence
+	silence = Silence{
+		Probe:     request.Probe,
+		Container: request.Container,
+		Type:      request.Type,
+		Start:     time.Now(),
+		Comment:   request.Comment,
+	}
+	silence.CreatedBy, _, _ = r.BasicAuth()
+	if request.Start != "" {
+		silence.Start, err = time.Parse(time.RFC3339, request.Start)
+		if err != nil {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+	}
+	if request.Duration != "" {
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+		silence.End = silence.Start.Add(duration)
+	} else {
+		silence.End, err = time.Parse(time.RFC3339, request.End)
+		if err != nil {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+	}
+
+	// Insert silence
+	silence, err = InsertSilence(silence)
+	if err != nil {
+		l.Error("HTTPHandlerSilencesCreate: Failed to insert silence:", err)
+		if strings.HasPrefix(err.Error(), "Bad") {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+	l.Info("Silence", silence.ID, "created by", silence.CreatedBy, "until", silence.End)
+
+	// silence => json
+	tmpJSON, err := json.Marshal(silence)
+	if err != nil {
+		l.Error("HTTPHandlerSilencesCreate: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	returnStr = string(tmpJSON)
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	w.WriteHeader(201)
+	fmt.Fprint(w, returnStr)
+}
+
+/*
+	Delete a silence
+*/
+func HTTPHandlerSilencesDelete(w http.ResponseWriter, r *http.Request) {
+	var muxVars = mux.Vars(r) // Mux Vars
+
+	err := DeleteSilence(muxVars["id"])
+	if err != nil {
+		if err.Error() == "Not found" {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		l.Error("HTTPHandlerSilencesDelete: Failed to delete silence:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+	user, _, _ := r.BasicAuth()
+	l.Info("Silence", muxVars["id"], "deleted by", user)
+
+	AddCORS(w)
+	w.WriteHeader(204)
+}
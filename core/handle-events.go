@@ -0,0 +1,66 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+/*
+	Return events history
+*/
+func HTTPHandlerEvents(w http.ResponseWriter, r *http.Request) {
+	var returnStr string              // HTTP Response body
+	var returnedEvents []EventMessage // Returned events
+	var options Options               // Options
+	var filters EventFilters          // Filters
+	var err error                     // Error handling
+
+	options = GetOptions(r)
+	filters = ParseEventFilters(r)
+
+	// Check filters
+	err = CheckEventsRequest(filters, options)
+	if err != nil {
+		l.Debug("HTTPHandlerEvents: Bad request:", err)
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+
+	returnedEvents, err = GetEvents(filters, options)
+	if err != nil {
+		l.Error("HTTPHandlerEvents: Failed to get events:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// returnedEvents => json
+	tmpJSON, err := json.Marshal(returnedEvents)
+	if err != nil {
+		l.Error("HTTPHandlerEvents: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// Add json to the returned string
+	returnStr = string(tmpJSON)
+	if returnStr == "null" {
+		returnStr = "[]"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	fmt.Fprint(w, returnStr)
+}
+
+/*
+	Get the filters of an events request
+*/
+func ParseEventFilters(r *http.Request) EventFilters {
+	return EventFilters{
+		Probe:     r.URL.Query().Get("probe"),
+		Container: r.URL.Query().Get("container"),
+		Type:      r.URL.Query().Get("type"),
+		Severity:  r.URL.Query().Get("severity"),
+	}
+}
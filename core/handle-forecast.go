@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+	Return the disk forecast of a probe
+*/
+func HTTPHandlerForecastProbeName(w http.ResponseWriter, r *http.Request) {
+	var muxVars = mux.Vars(r) // Mux Vars
+
+	f, err := ForecastProbe(muxVars["name"])
+	writeForecast(w, f, err)
+}
+
+/*
+	Return the disk forecast of a container
+*/
+func HTTPHandlerForecastCID(w http.ResponseWriter, r *http.Request) {
+	var muxVars = mux.Vars(r) // Mux Vars
+
+	f, err := ForecastContainer(muxVars["cid"])
+	writeForecast(w, f, err)
+}
+
+/*
+	Write a forecast in a HTTP response
+*/
+func writeForecast(w http.ResponseWriter, f Forecast, err error) {
+	if err != nil {
+		if strings.Contains(err.Error(), "Not found") || strings.Contains(err.Error(), "Not enough samples") {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		l.Error("writeForecast: Failed to forecast:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// f => json
+	tmpJSON, err := json.Marshal(f)
+	if err != nil {
+		l.Error("writeForecast: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	fmt.Fprint(w, string(tmpJSON))
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+	Return the memory leak report of a container
+*/
+func HTTPHandlerMemoryLeakCID(w http.ResponseWriter, r *http.Request) {
+	var muxVars = mux.Vars(r) // Mux Vars
+
+	report, err := CheckMemoryLeak(muxVars["cid"])
+	if err != nil {
+		if strings.Contains(err.Error(), "Not found") || strings.Contains(err.Error(), "Not enough samples") {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		l.Error("HTTPHandlerMemoryLeakCID: Failed to check memory leak:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// report => json
+	tmpJSON, err := json.Marshal(report)
+	if err != nil {
+		l.Error("HTTPHandlerMemoryLeakCID: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	fmt.Fprint(w, string(tmpJSON))
+}
@@ -11,22 +11,24 @@ import (
 )
 
 /*
-	Return simplified probes array
+	Return simplified probes array (with the probes health)
 */
 func HTTPHandlerProbes(w http.ResponseWriter, r *http.Request) {
-	var returnStr string                 // HTTP Response body
-	var returnProbes []dguard.ProbeInfos // Returned probes
-	var populate string                  // HTTP GET parameter
-	var err error                        // Error handling
+	var returnStr string           // HTTP Response body
+	var returnProbes []ProbeStatus // Returned probes
+	var populate string            // HTTP GET parameter
+	var err error                  // Error handling
 
 	// Check if populate is true
 	populate = r.URL.Query().Get("populate")
 
 	// Get probes
-	returnProbes = GetProbesInfos()
+	returnProbes = GetProbesStatus()
 
 	// If populate == true, insert containers
 	if populate == "true" {
+		ProbeLastStatsMutex.Lock()
+		defer ProbeLastStatsMutex.Unlock()
 		for i, probe := range returnProbes {
 			var ok bool
 			returnProbes[i].Containers, ok = ProbeLastStats[probe.Name]
@@ -121,3 +123,116 @@ func HTTPHandlerProbesName(w http.ResponseWriter, r *http.Request) {
 		returnStr = "[]"
 	}
 }
+
+/*
+	Add a probe
+*/
+func HTTPHandlerProbesCreate(w http.ResponseWriter, r *http.Request) {
+	var probe Probe // Request body
+	var err error   // Error handling
+
+	// Parse body
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize)).Decode(&probe)
+	if err != nil {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+
+	// Add probe
+	probe, err = AddProbe(probe)
+	if err != nil {
+		l.Error("HTTPHandlerProbesCreate: Failed to add probe:", err)
+		if strings.HasPrefix(err.Error(), "Bad") {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+		if err.Error() == "Already exists" {
+			http.Error(w, http.StatusText(409), 409)
+			return
+		}
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	writeProbe(w, probe, 201)
+}
+
+/*
+	Reconfigure a probe
+*/
+func HTTPHandlerProbesUpdate(w http.ResponseWriter, r *http.Request) {
+	var muxVars = mux.Vars(r) // Mux Vars
+	var probe Probe           // Request body
+	var err error             // Error handling
+
+	// Parse body
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize)).Decode(&probe)
+	if err != nil {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+	if probe.Name != "" && probe.Name != muxVars["name"] {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+	probe.Name = muxVars["name"]
+
+	// Update probe
+	probe, err = UpdateProbe(probe)
+	if err != nil {
+		l.Error("HTTPHandlerProbesUpdate: Failed to update probe:", err)
+		if strings.HasPrefix(err.Error(), "Bad") {
+			http.Error(w, http.StatusText(400), 400)
+			return
+		}
+		if err.Error() == "Not found" {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	writeProbe(w, probe, 200)
+}
+
+/*
+	Remove a probe
+*/
+func HTTPHandlerProbesDelete(w http.ResponseWriter, r *http.Request) {
+	var muxVars = mux.Vars(r) // Mux Vars
+
+	err := DeleteProbe(muxVars["name"])
+	if err != nil {
+		if err.Error() == "Not found" {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		l.Error("HTTPHandlerProbesDelete: Failed to delete probe:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	AddCORS(w)
+	w.WriteHeader(204)
+}
+
+/*
+	Write a probe config (without its API password) in a HTTP response
+*/
+func writeProbe(w http.ResponseWriter, probe Probe, status int) {
+	probe.APIPassword = ""
+
+	// probe => json
+	tmpJSON, err := json.Marshal(probe)
+	if err != nil {
+		l.Error("writeProbe: Failed to marshal struct:", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	AddCORS(w)
+	w.WriteHeader(status)
+	fmt.Fprint(w, string(tmpJSON))
+}
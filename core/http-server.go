@@ -1,11 +1,17 @@
 package core
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
+var (
+	// API server
+	apiServer *http.Server
+)
+
 /*
 	Log HTTP requests' URI
 */
@@ -24,8 +30,9 @@ func HTTPSecureAPI(r *http.Request, rm *mux.RouteMatch) bool {
 	}
 
 	// Check basic auth
+	api := GetConfig().DockerGuard.API
 	u, p, ok := r.BasicAuth()
-	if ok == true && u == DGConfig.DockerGuard.API.APILogin && p == DGConfig.DockerGuard.API.APIPassword {
+	if ok == true && u == api.APILogin && p == api.APIPassword {
 		l.Debug("Auth OK from", r.RemoteAddr)
 		return true
 	}
@@ -46,7 +53,7 @@ func HTTPSecureAPI(r *http.Request, rm *mux.RouteMatch) bool {
 func AddCORS(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "Authorization,DNT,X-Mx-ReqToken,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 }
 
@@ -55,7 +62,7 @@ func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "OPTIONS" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Headers", "Authorization,DNT,X-Mx-ReqToken,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "1728000")
 		w.Header().Set("Content-Type", "text/plain charset=UTF-8")
@@ -66,8 +73,9 @@ func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 
 	// If header "Authorization" is not null and invalid, return 403
 	if r.Header.Get("Authorization") != "" {
+		api := GetConfig().DockerGuard.API
 		u, p, ok := r.BasicAuth()
-		if ok == true && u == DGConfig.DockerGuard.API.APILogin && p == DGConfig.DockerGuard.API.APIPassword {
+		if ok == true && u == api.APILogin && p == api.APIPassword {
 			http.Error(w, http.StatusText(404), 404)
 			return
 		}
@@ -78,7 +86,7 @@ func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 /*
-	Run HTTP Server
+	Launch HTTP Server
 */
 func HTTPServer() {
 	r := mux.NewRouter()
@@ -86,6 +94,9 @@ func HTTPServer() {
 	r1 := r.MatcherFunc(HTTPURILogger).MatcherFunc(HTTPSecureAPI).Subrouter()
 	// r1 := r.MatcherFunc(HTTPURILogger).Subrouter()
 	rGET := r1.Methods("GET").Subrouter()
+	rPOST := r1.Methods("POST").Subrouter()
+	rPUT := r1.Methods("PUT").Subrouter()
+	rDELETE := r1.Methods("DELETE").Subrouter()
 	// rOPTIONS := r.MatcherFunc(HTTPURILogger).Methods("OPTIONS").Subrouter()
 
 	rGET.HandleFunc("/containers", HTTPHandlerContainers)
@@ -96,7 +107,39 @@ func HTTPServer() {
 	rGET.HandleFunc("/stats", HTTPHandlerStats)
 	rGET.HandleFunc("/stats/probe/{name:[0-9a-zA-Z-_]+}", HTTPHandlerStatsProbeName)
 	rGET.HandleFunc("/stats/container/{cid:[0-9a-z]+}", HTTPHandlerStatsCID)
+	rGET.HandleFunc("/forecast/probe/{name:[0-9a-zA-Z-_]+}", HTTPHandlerForecastProbeName)
+	rGET.HandleFunc("/forecast/container/{cid:[0-9a-z]+}", HTTPHandlerForecastCID)
+	rGET.HandleFunc("/memoryleak/container/{cid:[0-9a-z]+}", HTTPHandlerMemoryLeakCID)
+	rGET.HandleFunc("/events", HTTPHandlerEvents)
+	rGET.HandleFunc("/alerts", HTTPHandlerAlerts)
+	rGET.HandleFunc("/silences", HTTPHandlerSilences)
+	rPOST.HandleFunc("/alerts/ack", HTTPHandlerAlertsAck)
+	rPOST.HandleFunc("/silences", HTTPHandlerSilencesCreate)
+	rPOST.HandleFunc("/probes", HTTPHandlerProbesCreate)
+	rPUT.HandleFunc("/probes/{name:[0-9a-zA-Z-_]+}", HTTPHandlerProbesUpdate)
+	rDELETE.HandleFunc("/probes/{name:[0-9a-zA-Z-_]+}", HTTPHandlerProbesDelete)
+	rDELETE.HandleFunc("/silences/{id:[0-9a-f]+}", HTTPHandlerSilencesDelete)
 	http.Handle("/", r)
 
-	http.ListenAndServe(DGConfig.DockerGuard.API.ListenInterface+":"+DGConfig.DockerGuard.API.ListenPort, r)
+	api := GetConfig().DockerGuard.API
+	apiServer = &http.Server{
+		Addr:    api.ListenInterface + ":" + api.ListenPort,
+		Handler: r,
+	}
+	go func() {
+		err := apiServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			l.Critical("API server error:", err)
+		}
+	}()
+}
+
+/*
+	Stop accepting API requests and wait for the running ones
+*/
+func StopHTTPServer(ctx context.Context) error {
+	if apiServer == nil {
+		return nil
+	}
+	return apiServer.Shutdown(ctx)
 }
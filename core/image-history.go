@@ -0,0 +1,252 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+const (
+	// File to store imageHistory
+	ImageHistoryFilePath = "./images.json"
+	// Max number of images kept in the history of a hostname
+	MaxImageHistory = 10
+	// Hostnames not seen during this duration are forgotten
+	ImageHistoryRetention = 7 * 24 * time.Hour
+)
+
+/*
+	Image used by a container
+	(ImageID is empty if the probe doesn't report it)
+*/
+type ImageChange struct {
+	ContainerID string
+	Image       string
+	ImageID     string `json:",omitempty"`
+	Since       time.Time
+}
+
+/*
+	Containers and images history of a hostname
+	(the last item of History is the current container)
+*/
+type HostnameHistory struct {
+	History  []ImageChange
+	LastSeen time.Time
+}
+
+var (
+	// map[PROBE_NAME] => map[HOSTNAME] => HostnameHistory
+	imageHistory map[string]map[string]*HostnameHistory
+	// imageHistory's Mutex
+	ImageHistoryMutex sync.Mutex
+)
+
+/*
+	Initialize image history
+*/
+func InitImageHistory() {
+	// Make map
+	imageHistory = make(map[string]map[string]*HostnameHistory)
+
+	// Check if ImageHistoryFilePath exists
+	if utils.FileExists(ImageHistoryFilePath) {
+		// Load imageHistory from the file
+		err := LoadImageHistoryFromFile()
+		if err != nil {
+			l.Critical("Can't load image history from file:", err)
+		}
+	}
+}
+
+/*
+	Load imageHistory from a file
+*/
+func LoadImageHistoryFromFile() error {
+	// Lock / Unlock imageHistory
+	ImageHistoryMutex.Lock()
+	defer ImageHistoryMutex.Unlock()
+
+	// Read the file
+	content, err := utils.FileReadAllBytes(ImageHistoryFilePath)
+	if err != nil {
+		return errors.New("LoadImageHistoryFromFile: Failed to read history in file: " + err.Error())
+	}
+
+	// Parse the file
+	err = json.Unmarshal(content, &imageHistory)
+	if err != nil {
+		return errors.New("LoadImageHistoryFromFile: Failed to unmarshal struct: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+	Save imageHistory to a file
+	(imageHistory must be locked by the caller)
+*/
+func saveImageHistoryToFile() error {
+	// Forget old hostnames
+	for _, hostnames := range imageHistory {
+		for hostname, h := range hostnames {
+			if time.Since(h.LastSeen) > ImageHistoryRetention {
+				delete(hostnames, hostname)
+			}
+		}
+	}
+
+	// imageHistory => json
+	tmpJSON, err := json.Marshal(imageHistory)
+	if err != nil {
+		return errors.New("SaveImageHistoryToFile: Failed to marshal struct: " + err.Error())
+	}
+
+	// Write json to file
+	err = utils.FileWriteAllBytes(ImageHistoryFilePath, tmpJSON)
+	if err != nil {
+		return errors.New("SaveImageHistoryToFile: Failed to write history in file: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+	Forget the image history of a deleted probe
+*/
+func DeleteImageHistory(probeName string) {
+	// Lock / Unlock imageHistory
+	ImageHistoryMutex.Lock()
+	defer ImageHistoryMutex.Unlock()
+
+	delete(imageHistory, probeName)
+	saveImageHistoryToFile()
+}
+
+/*
+	Get the image IDs of the containers of a probe's containers list body
+	(map[CONTAINER_ID] => IMAGE_ID, empty if the probe doesn't report them)
+*/
+func containerImageIDs(body []byte) map[string]string {
+	var list map[string]struct{ ImageID string } // Containers list
+	var imageIDs = make(map[string]string)
+
+	if json.Unmarshal(body, &list) != nil {
+		return imageIDs
+	}
+	for id, c := range list {
+		if c.ImageID != "" {
+			imageIDs[id] = c.ImageID
+		}
+	}
+
+	return imageIDs
+}
+
+/*
+	Return the description of an image
+*/
+func (i ImageChange) describe() string {
+	if i.ImageID == "" {
+		return i.Image
+	}
+	return i.Image + " (" + i.ImageID + ")"
+}
+
+/*
+	Compare the containers of a probe with the history of their hostnames,
+	and return the ContainerReplaced / ContainerImageChanged events to send
+	and the IDs of the replaced and replacing containers
+
+	A hostname used by a new container is replaced if its old container
+	doesn't exist anymore, and its image changed if the new container doesn't
+	use the same image (name, or ID if the probe reports it, so a moved tag
+	is a change). Hostnames shared by several containers are ignored.
+*/
+func CheckContainerImages(probeName string, containers map[string]*dguard.Container, imageIDs map[string]string) ([]dguard.Event, map[string]bool) {
+	var events []dguard.Event                           // Events to send
+	var replaced = make(map[string]bool)                // Replaced and replacing containers
+	var byHostname = make(map[string]*dguard.Container) // Containers by hostname
+	var shared = make(map[string]bool)                  // Hostnames shared by several containers
+	var changed bool                                    // True if imageHistory must be saved
+	var now = time.Now()
+
+	for _, c := range containers {
+		if _, ok := byHostname[c.Hostname]; ok {
+			shared[c.Hostname] = true
+		}
+		byHostname[c.Hostname] = c
+	}
+
+	// Lock / Unlock imageHistory
+	ImageHistoryMutex.Lock()
+	defer ImageHistoryMutex.Unlock()
+
+	hostnames, ok := imageHistory[probeName]
+	if !ok {
+		hostnames = make(map[string]*HostnameHistory)
+		imageHistory[probeName] = hostnames
+	}
+	for hostname, c := range byHostname {
+		var current = ImageChange{c.ID, c.Image, imageIDs[c.ID], now}
+
+		if shared[hostname] {
+			continue
+		}
+		h, ok := hostnames[hostname]
+		if !ok {
+			h = &HostnameHistory{History: []ImageChange{current}}
+			hostnames[hostname] = h
+			changed = true
+		}
+		h.LastSeen = now
+
+		last := &h.History[len(h.History)-1]
+		imageChanged := last.Image != current.Image ||
+			(last.ImageID != "" && current.ImageID != "" && last.ImageID != current.ImageID)
+		if last.ContainerID == c.ID && !imageChanged {
+			// Image ID reported for the first time
+			if last.ImageID == "" && current.ImageID != "" {
+				last.ImageID = current.ImageID
+				changed = true
+			}
+			continue
+		}
+		if _, ok := containers[last.ContainerID]; ok && last.ContainerID != c.ID {
+			// The old container still exists
+			continue
+		}
+
+		var event = dguard.Event{
+			Severity: dguard.EventNotice,
+			Type:     EventContainerReplaced,
+			Target:   c.Hostname + " (" + c.ID + ")",
+			Probe:    probeName,
+			Data:     "Old container: " + last.ContainerID + ", old image: " + last.describe() + ", new image: " + current.describe()}
+		if imageChanged {
+			event.Severity = dguard.EventWarning
+			event.Type = EventContainerImageChanged
+		}
+		events = append(events, event)
+		if last.ContainerID != c.ID {
+			replaced[last.ContainerID] = true
+			replaced[c.ID] = true
+		}
+
+		h.History = append(h.History, current)
+		if len(h.History) > MaxImageHistory {
+			h.History = h.History[len(h.History)-MaxImageHistory:]
+		}
+		changed = true
+	}
+	if changed {
+		saveImageHistoryToFile()
+	}
+
+	return events, replaced
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Step of an image history test: the containers of the probe (ID =>
+	hostname, image and image ID), and the expected event types and
+	replaced containers
+*/
+type imageStep struct {
+	name       string
+	containers map[string][3]string
+	events     []int
+	replaced   []string
+}
+
+func TestCheckContainerImages(t *testing.T) {
+	var steps = []imageStep{
+		{"new hostname",
+			map[string][3]string{"a": {"web", "nginx:1", ""}}, nil, nil},
+		{"same container",
+			map[string][3]string{"a": {"web", "nginx:1", ""}}, nil, nil},
+		{"image ID reported for the first time",
+			map[string][3]string{"a": {"web", "nginx:1", "sha1"}}, nil, nil},
+		{"container replaced",
+			map[string][3]string{"b": {"web", "nginx:1", "sha1"}},
+			[]int{EventContainerReplaced}, []string{"a", "b"}},
+		{"image changed",
+			map[string][3]string{"c": {"web", "nginx:2", "sha2"}},
+			[]int{EventContainerImageChanged}, []string{"b", "c"}},
+		{"tag moved",
+			map[string][3]string{"d": {"web", "nginx:2", "sha3"}},
+			[]int{EventContainerImageChanged}, []string{"c", "d"}},
+		{"old container still exists",
+			map[string][3]string{"d": {"api", "nginx:2", "sha3"}, "e": {"web", "nginx:3", ""}},
+			nil, nil},
+		{"shared hostname",
+			map[string][3]string{"f": {"db", "mysql:5", ""}, "g": {"db", "mysql:8", ""}},
+			nil, nil},
+	}
+
+	imageHistory = make(map[string]map[string]*HostnameHistory)
+	defer DeleteImageHistory("probe1")
+
+	for _, step := range steps {
+		var containers = make(map[string]*dguard.Container)
+		var imageIDs = make(map[string]string)
+		var types []int
+		var replaced []string
+
+		for id, c := range step.containers {
+			containers[id] = &dguard.Container{ID: id, Hostname: c[0], Image: c[1]}
+			if c[2] != "" {
+				imageIDs[id] = c[2]
+			}
+		}
+		events, r := CheckContainerImages("probe1", containers, imageIDs)
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		for _, id := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			if r[id] {
+				replaced = append(replaced, id)
+			}
+		}
+		if !reflect.DeepEqual(types, step.events) {
+			t.Errorf("%s: events %v, expected %v", step.name, types, step.events)
+		}
+		if !reflect.DeepEqual(replaced, step.replaced) {
+			t.Errorf("%s: replaced %v, expected %v", step.name, replaced, step.replaced)
+		}
+	}
+}
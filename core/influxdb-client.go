@@ -40,7 +40,8 @@ type Options struct {
 }
 
 const (
-	StatsMeasurements = "cstats"
+	StatsMeasurements      = "cstats"
+	ProbeStatsMeasurements = "pstats"
 )
 
 /*
@@ -56,9 +57,10 @@ var (
 */
 func InitDB() {
 	var err error
+	var dbConf = GetConfig().DockerGuard.InfluxDB
 
 	// Parse InfluxDB server URL
-	u, err := url.Parse(fmt.Sprintf("http://%s:%d", DGConfig.DockerGuard.InfluxDB.IP, DGConfig.DockerGuard.InfluxDB.Port))
+	u, err := url.Parse(fmt.Sprintf("http://%s:%d", dbConf.IP, dbConf.Port))
 	if err != nil {
 		l.Critical("Can't parse InfluxDB config :", err)
 	}
@@ -84,7 +86,7 @@ func InitDB() {
 	l.Verbose("Connected to InfluxDB! ping:", dur, "/ version:", ver)
 
 	// Create DB if doesn't exist
-	_, err = queryDB(DB, "create database "+DGConfig.DockerGuard.InfluxDB.DB)
+	_, err = queryDB(DB, "create database "+dbConf.DB)
 	if err != nil {
 		if err.Error() != "database already exists" {
 			l.Critical("Create DB:", err)
@@ -98,7 +100,7 @@ func InitDB() {
 func queryDB(con *influxdb.Client, cmd string) (res []influxdb.Result, err error) {
 	q := influxdb.Query{
 		Command:  cmd,
-		Database: DGConfig.DockerGuard.InfluxDB.DB,
+		Database: GetConfig().DockerGuard.InfluxDB.DB,
 	}
 	if response, err := con.Query(q); err == nil {
 		if response.Error() != nil {
@@ -165,7 +167,7 @@ func (s *Stat) Insert(probeName string) error {
 	// InfluxDB batch points
 	bps := influxdb.BatchPoints{
 		Points:          pts,
-		Database:        DGConfig.DockerGuard.InfluxDB.DB,
+		Database:        GetConfig().DockerGuard.InfluxDB.DB,
 		RetentionPolicy: "default",
 	}
 
@@ -219,7 +221,7 @@ func InsertStats(stats []Stat, probeName string) error {
 	// InfluxDB batch points
 	bps := influxdb.BatchPoints{
 		Points:          pts,
-		Database:        DGConfig.DockerGuard.InfluxDB.DB,
+		Database:        GetConfig().DockerGuard.InfluxDB.DB,
 		RetentionPolicy: "default",
 	}
 
@@ -235,6 +237,48 @@ func InsertStats(stats []Stat, probeName string) error {
 	return err
 }
 
+/*
+	Insert a probe stat
+*/
+func InsertProbeStat(infos *dguard.ProbeInfos) error {
+	var pts = make([]influxdb.Point, 1) // InfluxDB point
+	var err error                       // Error handling
+
+	// Make InfluxDB point
+	pts[0] = influxdb.Point{
+		Measurement: ProbeStatsMeasurements,
+		Tags: map[string]string{
+			"probename": infos.Name,
+		},
+		Fields: map[string]interface{}{
+			"diskavailable":   float64(infos.DiskAvailable),
+			"disktotal":       float64(infos.DiskTotal),
+			"memoryavailable": float64(infos.MemoryAvailable),
+			"memorytotal":     float64(infos.MemoryTotal),
+		},
+		Time:      time.Now(),
+		Precision: "s",
+	}
+
+	// InfluxDB batch points
+	bps := influxdb.BatchPoints{
+		Points:          pts,
+		Database:        GetConfig().DockerGuard.InfluxDB.DB,
+		RetentionPolicy: "default",
+	}
+
+	// Write point in InfluxDB server
+	timer := time.Now()
+	_, err = DB.Write(bps)
+	if err != nil {
+		l.Error("Failed to write in InfluxDB:", bps, ". Error:", err)
+	} else {
+		l.Silly("Probe stat inserted in ", time.Since(timer), ":", bps)
+	}
+
+	return err
+}
+
 /*
 	Get container's last stat
 */
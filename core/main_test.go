@@ -0,0 +1,40 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	l = InitLogger(false, false, false)
+
+	// State files (alerts.json, dead-letters.log, ...) are written in a
+	// temporary directory
+	dir, err := ioutil.TempDir("", "dgm-test")
+	if err != nil {
+		panic(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		panic(err)
+	}
+	alertList = make(map[string]*AlertState)
+	setConfig(new(Config))
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+/*
+	Change a copy of the config during a test and return the function
+	restoring the old config
+*/
+func changeConfig(change func(c *Config)) func() {
+	var c = *GetConfig()
+
+	change(&c)
+	old := setConfig(&c)
+	return func() { setConfig(old) }
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+const (
+	// Default history examined by the memory leak detector
+	DefaultMemoryLeakWindow = 6 * time.Hour
+	// Default interval between two checks of every container
+	DefaultMemoryLeakInterval = 10 * time.Minute
+	// Default min memory growth (in percent) of a leak
+	DefaultMemoryLeakMinGrowth = 10
+	// Number of periods of the window
+	MemoryLeakPeriods = 12
+	// Memory drop (in percent) tolerated between two periods of a leak
+	MemoryLeakTolerance = 1
+)
+
+/*
+	Memory leak detector config
+
+	The memory used by each container during the last Window is split in
+	periods. A leak is suspected when the lowest memory usage of each period
+	(after GC-like drops) never decreases and grows by at least MinGrowth
+	percent. If Enabled, every container is checked every Interval and a
+	MemoryLeakSuspected warning is sent.
+*/
+type MemoryLeakConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Window    string  `yaml:"window"`
+	Interval  string  `yaml:"interval"`
+	MinGrowth float64 `yaml:"min-growth"`
+	window    time.Duration
+	interval  time.Duration
+}
+
+/*
+	Memory leak report of a container
+
+	Slope is the growth of the lowest memory usage in bytes per second
+	(computed by linear regression) and Growth its growth in percent during
+	the window.
+*/
+type MemoryLeakReport struct {
+	Target    string
+	Probe     string
+	Samples   int
+	Slope     float64
+	Growth    float64
+	Suspected bool
+}
+
+/*
+	Check the memory leak detector config and set the default values
+*/
+func (c *MemoryLeakConfig) Check() error {
+	var err error // Error handling
+
+	c.window = DefaultMemoryLeakWindow
+	if c.Window != "" {
+		c.window, err = time.ParseDuration(c.Window)
+		if err != nil || c.window < MemoryLeakPeriods*time.Second {
+			return errors.New("Bad window: " + c.Window)
+		}
+	}
+	c.interval = DefaultMemoryLeakInterval
+	if c.Interval != "" {
+		c.interval, err = time.ParseDuration(c.Interval)
+		if err != nil || c.interval <= 0 {
+			return errors.New("Bad interval: " + c.Interval)
+		}
+	}
+	if c.MinGrowth == 0 {
+		c.MinGrowth = DefaultMemoryLeakMinGrowth
+	}
+	if c.MinGrowth < 0 {
+		return errors.New("Bad min-growth: must be positive")
+	}
+
+	return nil
+}
+
+/*
+	Launch the memory leak detector loop
+*/
+func InitMemoryLeakDetector() {
+	go memoryLeakLoop()
+}
+
+/*
+	Check every container, and send or resolve MemoryLeakSuspected alerts
+	(the config is read at each iteration, so it can be reloaded)
+*/
+func memoryLeakLoop() {
+	for {
+		select {
+		case <-time.After(GetConfig().DockerGuard.MemoryLeak.interval):
+		case <-shutdownCtx.Done():
+			return
+		}
+		if !GetConfig().DockerGuard.MemoryLeak.Enabled {
+			continue
+		}
+
+		for _, p := range GetProbes() {
+			containers, err := GetContainersByProbe(p.Name)
+			if err != nil {
+				continue
+			}
+			for _, c := range containers {
+				report, err := CheckMemoryLeak(c.ID)
+				if err != nil {
+					l.Debug("memoryLeakLoop: Can't check container", c.ID+":", err)
+					continue
+				}
+
+				var event = dguard.Event{
+					Severity: dguard.EventWarning,
+					Type:     EventMemoryLeakSuspected,
+					Target:   report.Target,
+					Probe:    report.Probe,
+					Data: fmt.Sprintf("Memory grew by %.1f%% in %s (%.0f bytes/h)",
+						report.Growth, GetConfig().DockerGuard.MemoryLeak.window, report.Slope*3600)}
+				if report.Suspected {
+					Alert(event)
+				} else {
+					ResolveAlert(event)
+				}
+			}
+		}
+	}
+}
+
+/*
+	Check if a container leaks memory
+*/
+func CheckMemoryLeak(containerCID string) (MemoryLeakReport, error) {
+	var report MemoryLeakReport
+	var conf = GetConfig().DockerGuard.MemoryLeak
+
+	c, err := GetContainerByCID(containerCID)
+	if err != nil {
+		return report, err
+	}
+	report = MemoryLeakReport{
+		Target: c.Hostname + " (" + c.ID + ")",
+		Probe:  c.Probe,
+	}
+
+	// Get the lowest memory usage of each period
+	query := "SELECT min(sizememory) FROM " + StatsMeasurements +
+		" WHERE containerid = " + influxQuote(c.ID) +
+		fmt.Sprintf(" AND time > now() - %ds GROUP BY time(%ds)",
+			int(conf.window.Seconds()), int(conf.window.Seconds())/MemoryLeakPeriods)
+	samples, err := querySamples(query)
+	if err != nil {
+		return report, err
+	}
+	report.analyze(samples, conf.MinGrowth)
+
+	return report, nil
+}
+
+/*
+	Compute the growth of the lowest memory usage of each period (unix time,
+	bytes) and check if a leak is suspected
+*/
+func (r *MemoryLeakReport) analyze(samples [][2]float64, minGrowth float64) {
+	r.Samples = len(samples)
+	if len(samples) == 0 {
+		return
+	}
+	r.Slope = linearRegression(samples)
+	first, last := samples[0][1], samples[len(samples)-1][1]
+	if first > 0 {
+		r.Growth = (last - first) / first * 100
+	}
+
+	// The lowest memory usage must never decrease
+	r.Suspected = r.Slope > 0 && r.Growth >= minGrowth
+	for i := 1; i < len(samples) && r.Suspected; i++ {
+		if samples[i][1] < samples[i-1][1]*(1-MemoryLeakTolerance/100.0) {
+			r.Suspected = false
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLeakAnalyze(t *testing.T) {
+	var tests = []struct {
+		name      string
+		values    []float64
+		growth    float64
+		suspected bool
+	}{
+		{"steady growth", []float64{100, 102, 104, 106, 108, 110, 112}, 12, true},
+		{"growth under min-growth", []float64{100, 101, 102, 103, 104, 105}, 5, false},
+		{"stable", []float64{100, 100, 100, 100}, 0, false},
+		{"memory released", []float64{100, 110, 120, 80, 90, 130}, 30, false},
+		{"drop under the tolerance", []float64{100, 110, 109.5, 120, 130}, 30, true},
+		{"decreasing", []float64{130, 120, 110, 100}, -100.0 / 13 * 3, false},
+		{"no memory at first", []float64{0, 10, 20}, 0, false},
+	}
+
+	for _, test := range tests {
+		var report MemoryLeakReport
+		var samples [][2]float64
+		var start = float64(time.Now().Unix())
+
+		for i, v := range test.values {
+			samples = append(samples, [2]float64{start + float64(i*1800), v})
+		}
+		report.analyze(samples, 10)
+
+		if report.Samples != len(samples) {
+			t.Errorf("%s: %d samples, expected %d", test.name, report.Samples, len(samples))
+		}
+		if d := report.Growth - test.growth; d > 1e-9 || d < -1e-9 {
+			t.Errorf("%s: growth %v, expected %v", test.name, report.Growth, test.growth)
+		}
+		if report.Suspected != test.suspected {
+			t.Errorf("%s: suspected = %v, expected %v", test.name, report.Suspected, test.suspected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+const (
+	// Default number of consecutive failures before a probe is unreachable
+	DefaultProbeFailures = 3
+	// Default max delay between two requests to a failing probe
+	DefaultProbeBackoff = 5 * time.Minute
+)
+
+/*
+	Probe health
+
+	Failures is the number of consecutive failed requests to the probe,
+	DownSince the time of the first one and Alerted is true if the
+	ProbeUnreachable event was sent. The failing probe is requested again at
+	NextAttempt: the delay is doubled at each failure, from the reload time
+	to the probe-backoff of the config.
+*/
+type ProbeHealth struct {
+	Failures    int
+	LastError   string
+	DownSince   time.Time
+	NextAttempt time.Time
+	Alerted     bool
+	mutex       sync.Mutex
+}
+
+/*
+	Probe health returned by the API
+*/
+type ProbeHealthState struct {
+	ConsecutiveFailures int
+	LastError           string     `json:",omitempty"`
+	NextAttempt         *time.Time `json:",omitempty"`
+}
+
+/*
+	Make the health of a probe
+	(a ProbeUnreachable alert firing before a restart is kept, so that it is
+	resolved when the probe answers)
+*/
+func NewProbeHealth(probeName string) *ProbeHealth {
+	var h = new(ProbeHealth)
+	var event = dguard.Event{Type: EventProbeUnreachable, Target: probeName, Probe: probeName}
+
+	if a, ok := GetAlertState("", event); ok && a.Status == AlertFiring {
+		h.Alerted = true
+		h.DownSince = a.Since
+	}
+
+	return h
+}
+
+/*
+	Record a failed request to a probe, schedule the next request and send a
+	ProbeUnreachable event after too many consecutive failures
+	(return true if the error differs from the previous one)
+*/
+func (h *ProbeHealth) Failed(probeName string, err string, interval time.Duration) bool {
+	var maxFailures = GetConfig().DockerGuard.Event.ProbeFailures
+	var event dguard.Event
+	var newError bool
+	var delay time.Duration
+
+	if maxFailures <= 0 {
+		maxFailures = DefaultProbeFailures
+	}
+
+	// Lock / Unlock health
+	h.mutex.Lock()
+	if h.Failures == 0 && !h.Alerted {
+		h.DownSince = time.Now()
+	}
+	h.Failures++
+	newError = h.LastError != err
+	h.LastError = err
+	delay = backoff(interval, h.Failures)
+	h.NextAttempt = time.Now().Add(delay)
+	if h.Alerted || h.Failures < maxFailures {
+		h.mutex.Unlock()
+		l.Verbose("Probe", probeName, "failed", h.Failures, "times, next attempt in", delay)
+		return newError
+	}
+	h.Alerted = true
+	event = dguard.Event{
+		Severity: dguard.EventCritical,
+		Type:     EventProbeUnreachable,
+		Target:   probeName,
+		Probe:    probeName,
+		Data:     "Down since " + h.DownSince.Format(time.RFC3339) + ", last error: " + h.LastError}
+	h.mutex.Unlock()
+
+	l.Warn("Probe", probeName, "is unreachable, next attempt in", delay)
+	Alert(event)
+	return newError
+}
+
+/*
+	Record a successful request to a probe, reset its backoff and send a
+	ProbeRecovered event if the probe was unreachable
+*/
+func (h *ProbeHealth) Succeeded(probeName string) {
+	var event dguard.Event
+
+	// Lock / Unlock health
+	h.mutex.Lock()
+	if !h.Alerted {
+		h.reset()
+		h.mutex.Unlock()
+		return
+	}
+	event = dguard.Event{
+		Severity: dguard.EventNotice,
+		Type:     EventProbeRecovered,
+		Target:   probeName,
+		Probe:    probeName,
+		Data:     "Down for " + (time.Since(h.DownSince) / time.Second * time.Second).String() + ", last error: " + h.LastError}
+	h.reset()
+	h.mutex.Unlock()
+
+	l.Info("Probe", probeName, "recovered")
+	Alert(event)
+}
+
+/*
+	Reset the failures of a probe
+	(health must be locked by the caller)
+*/
+func (h *ProbeHealth) reset() {
+	h.Failures = 0
+	h.LastError = ""
+	h.NextAttempt = time.Time{}
+	h.Alerted = false
+}
+
+/*
+	Get the delay before the next request to a failing probe
+	(return 0 if the probe isn't failing)
+*/
+func (h *ProbeHealth) Backoff() time.Duration {
+	// Lock / Unlock health
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.Failures == 0 {
+		return 0
+	}
+	return h.NextAttempt.Sub(time.Now())
+}
+
+/*
+	Get the health of a probe for the API
+*/
+func (h *ProbeHealth) State() ProbeHealthState {
+	var state ProbeHealthState
+
+	// Lock / Unlock health
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state.ConsecutiveFailures = h.Failures
+	state.LastError = h.LastError
+	if h.Failures > 0 {
+		var nextAttempt = h.NextAttempt
+		state.NextAttempt = &nextAttempt
+	}
+
+	return state
+}
+
+/*
+	Get the delay before the next request to a probe after failures
+	consecutive failures: the reload interval doubled at each failure,
+	up to the probe-backoff of the config
+*/
+func backoff(interval time.Duration, failures int) time.Duration {
+	var maxBackoff = GetConfig().DockerGuard.Event.probeBackoff
+	var delay = interval
+
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultProbeBackoff
+	}
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff && interval < maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	var tests = []struct {
+		interval   time.Duration
+		maxBackoff time.Duration
+		failures   int
+		expected   time.Duration
+	}{
+		{10 * time.Second, 5 * time.Minute, 1, 10 * time.Second},
+		{10 * time.Second, 5 * time.Minute, 2, 20 * time.Second},
+		{10 * time.Second, 5 * time.Minute, 4, 80 * time.Second},
+		{10 * time.Second, 5 * time.Minute, 6, 5 * time.Minute},
+		{10 * time.Second, 5 * time.Minute, 1000, 5 * time.Minute},
+		{10 * time.Second, 0, 10, DefaultProbeBackoff},
+		// The reload interval is never shortened
+		{10 * time.Minute, 5 * time.Minute, 1, 10 * time.Minute},
+		{10 * time.Minute, 5 * time.Minute, 3, 10 * time.Minute},
+	}
+
+	for _, test := range tests {
+		restore := changeConfig(func(c *Config) { c.DockerGuard.Event.probeBackoff = test.maxBackoff })
+		delay := backoff(test.interval, test.failures)
+		restore()
+		if delay != test.expected {
+			t.Errorf("backoff(%s, %d) with max %s = %s, expected %s",
+				test.interval, test.failures, test.maxBackoff, delay, test.expected)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+const (
+	// File to store the probes list
+	ProbeListFilePath = "./probes.json"
+
+	// Default timeout of the requests to a probe
+	DefaultProbeTimeout = 10 * time.Second
+)
+
+/*
+	Probe infos and health returned by the API
+*/
+type ProbeStatus struct {
+	dguard.ProbeInfos
+	Health ProbeHealthState
+}
+
+var (
+	// Probes' Mutex
+	ProbesMutex sync.Mutex
+	// Running probe monitors
+	probesWaitGroup sync.WaitGroup
+	// Valid probe names (as in the API routes)
+	probeNameRegexp = regexp.MustCompile("^[0-9a-zA-Z-_]+$")
+)
+
+/*
+	Initialize probes controller and launch the probe monitors
+	(the probes list is loaded from ProbeListFilePath if it exists, else
+	from the config: once the list is changed with the API, this file
+	replaces the probes of the config)
+*/
+func InitProbesController() {
+	var probes = GetConfig().Probes
+
+	// Check if ProbeListFilePath exists
+	if utils.FileExists(ProbeListFilePath) {
+		// Load the probes list from the file
+		content, err := utils.FileReadAllBytes(ProbeListFilePath)
+		if err != nil {
+			l.Critical("Can't load probes list from file:", err)
+		}
+		probes = nil
+		err = json.Unmarshal(content, &probes)
+		if err != nil {
+			l.Critical("Can't load probes list from file:", err)
+		}
+		for i := range probes {
+			err = probes[i].Check()
+			if err != nil {
+				l.Critical("Bad probe in probes list file:", err)
+			}
+		}
+	}
+
+	// Lock / Unlock Probes
+	ProbesMutex.Lock()
+	defer ProbesMutex.Unlock()
+
+	for _, p := range probes {
+		Probes = append(Probes, startProbe(p, nil, nil, nil))
+	}
+}
+
+/*
+	Save the probes list to a file
+	(Probes must be locked by the caller)
+*/
+func saveProbesToFile() error {
+	var probes = make([]Probe, len(Probes)) // Probes configs
+
+	for i, p := range Probes {
+		probes[i] = p.Config()
+	}
+
+	// probes => json
+	tmpJSON, err := json.Marshal(probes)
+	if err != nil {
+		return errors.New("SaveProbesToFile: Failed to marshal struct: " + err.Error())
+	}
+
+	// Write json to file
+	err = utils.FileWriteAllBytes(ProbeListFilePath, tmpJSON)
+	if err != nil {
+		return errors.New("SaveProbesToFile: Failed to write list in file: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+	Return the config of a probe (without its state)
+*/
+func (p *Probe) Config() Probe {
+	return Probe{
+		Name:        p.Name,
+		URI:         p.URI,
+		APIPassword: p.APIPassword,
+		ReloadTime:  p.ReloadTime,
+		Timeout:     p.Timeout,
+	}
+}
+
+/*
+	Return the interval between two reloads of a probe
+*/
+func (p *Probe) interval() time.Duration {
+	return time.Duration(p.ReloadTime * float64(time.Second))
+}
+
+/*
+	Return the timeout of the requests to a probe
+*/
+func (p *Probe) timeout() time.Duration {
+	if p.Timeout == 0 {
+		return DefaultProbeTimeout
+	}
+	return time.Duration(p.Timeout * float64(time.Second))
+}
+
+/*
+	Check if a probe config is valid
+*/
+func (p *Probe) Check() error {
+	if !probeNameRegexp.MatchString(p.Name) {
+		return errors.New("Bad name: " + p.Name)
+	}
+	if p.URI == "" {
+		return errors.New("Bad uri: empty")
+	}
+	if p.interval() <= 0 {
+		return errors.New("Bad reload-time: must be positive")
+	}
+	if p.Timeout < 0 {
+		return errors.New("Bad timeout: must be positive")
+	}
+	return nil
+}
+
+/*
+	Launch the monitor of a probe
+	(new infos and health are made if they are nil, the monitor is started
+	once the previous monitor of the probe is stopped if it isn't nil)
+*/
+func startProbe(config Probe, infos *dguard.ProbeInfos, health *ProbeHealth, previous *Probe) *Probe {
+	var ctx context.Context
+	var probe = config.Config()
+
+	probe.Infos, probe.Health = infos, health
+	if probe.Infos == nil {
+		probe.Infos = &dguard.ProbeInfos{Name: probe.Name}
+	}
+	if probe.Health == nil {
+		probe.Health = NewProbeHealth(probe.Name)
+	}
+	ctx, probe.cancel = context.WithCancel(shutdownCtx)
+	probe.done = make(chan struct{})
+
+	probesWaitGroup.Add(1)
+	go func() {
+		defer probesWaitGroup.Done()
+		defer close(probe.done)
+		if previous != nil {
+			previous.stop()
+		}
+		MonitorProbe(ctx, probe)
+	}()
+
+	return &probe
+}
+
+/*
+	Stop the monitor of a probe and wait for its end
+*/
+func (p *Probe) stop() {
+	p.cancel()
+	<-p.done
+}
+
+/*
+	Get a copy of the infos of a probe
+*/
+func (p *Probe) GetInfos() dguard.ProbeInfos {
+	// Lock / Unlock probes infos
+	ProbesInfosMutex.Lock()
+	defer ProbesInfosMutex.Unlock()
+
+	return *p.Infos
+}
+
+/*
+	Replace the infos of a probe
+*/
+func (p *Probe) setInfos(infos dguard.ProbeInfos) {
+	// Lock / Unlock probes infos
+	ProbesInfosMutex.Lock()
+	defer ProbesInfosMutex.Unlock()
+
+	*p.Infos = infos
+}
+
+/*
+	Set the running state of a probe in its infos
+*/
+func (p *Probe) setRunning(running bool) {
+	// Lock / Unlock probes infos
+	ProbesInfosMutex.Lock()
+	defer ProbesInfosMutex.Unlock()
+
+	p.Infos.Running = running
+}
+
+/*
+	Get the index of a probe in Probes, -1 if it doesn't exist
+	(Probes must be locked by the caller)
+*/
+func probeIndex(name string) int {
+	for i, p := range Probes {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+	Get a copy of the probes list
+*/
+func GetProbes() []*Probe {
+	// Lock / Unlock Probes
+	ProbesMutex.Lock()
+	defer ProbesMutex.Unlock()
+
+	return append([]*Probe(nil), Probes...)
+}
+
+/*
+	Get the infos and health of the probes
+*/
+func GetProbesStatus() []ProbeStatus {
+	var probes []ProbeStatus // List of probes status to return
+
+	for _, probe := range GetProbes() {
+		probes = append(probes, ProbeStatus{probe.GetInfos(), probe.Health.State()})
+	}
+
+	return probes
+}
+
+/*
+	Add a probe, launch its monitor and save the probes list
+*/
+func AddProbe(config Probe) (Probe, error) {
+	// Lock / Unlock Probes
+	ProbesMutex.Lock()
+	defer ProbesMutex.Unlock()
+
+	err := addProbe(config)
+	if err != nil {
+		return config, err
+	}
+	return config.Config(), saveProbesToFile()
+}
+
+/*
+	Reconfigure a probe, restart its monitor and save the probes list
+	(return once the old monitor is stopped)
+*/
+func UpdateProbe(config Probe) (Probe, error) {
+	// Lock Probes
+	ProbesMutex.Lock()
+	old, err := updateProbe(config)
+	if err != nil {
+		ProbesMutex.Unlock()
+		return config, err
+	}
+	err = saveProbesToFile()
+	// Unlock Probes
+	ProbesMutex.Unlock()
+
+	// The monitor may be waiting for Probes
+	old.stop()
+
+	return config.Config(), err
+}
+
+/*
+	Remove a probe with its containers and alerts, and save the probes list
+*/
+func DeleteProbe(name string) error {
+	// Lock Probes
+	ProbesMutex.Lock()
+	old, err := deleteProbe(name)
+	if err != nil {
+		ProbesMutex.Unlock()
+		return err
+	}
+	err = saveProbesToFile()
+	// Unlock Probes
+	ProbesMutex.Unlock()
+
+	// The monitor may be waiting for Probes
+	old.destroy()
+
+	return err
+}
+
+/*
+	Add a probe and launch its monitor
+	(Probes must be locked by the caller)
+*/
+func addProbe(config Probe) error {
+	err := config.Check()
+	if err != nil {
+		return err
+	}
+	if probeIndex(config.Name) != -1 {
+		return errors.New("Already exists")
+	}
+
+	Probes = append(Probes, startProbe(config, nil, nil, nil))
+	l.Info("Probe", config.Name, "added")
+
+	return nil
+}
+
+/*
+	Reconfigure a probe and restart its monitor, and return the old probe
+	(its infos and health are kept, the new monitor is started once the old
+	one is stopped; Probes must be locked by the caller)
+*/
+func updateProbe(config Probe) (*Probe, error) {
+	err := config.Check()
+	if err != nil {
+		return nil, err
+	}
+	i := probeIndex(config.Name)
+	if i == -1 {
+		return nil, errors.New("Not found")
+	}
+
+	old := Probes[i]
+	old.cancel()
+	Probes[i] = startProbe(config, old.Infos, old.Health, old)
+	l.Info("Probe", config.Name, "updated")
+
+	return old, nil
+}
+
+/*
+	Remove a probe and return it, its monitor must then be stopped with
+	destroy() (Probes must be locked by the caller)
+*/
+func deleteProbe(name string) (*Probe, error) {
+	i := probeIndex(name)
+	if i == -1 {
+		return nil, errors.New("Not found")
+	}
+
+	old := Probes[i]
+	Probes = append(Probes[:i], Probes[i+1:]...)
+	l.Info("Probe", name, "deleted")
+
+	return old, nil
+}
+
+/*
+	Stop the monitor of a removed probe and forget its containers and alerts
+	(Probes must not be locked by the caller: the monitor may be waiting for
+	it)
+*/
+func (p *Probe) destroy() {
+	p.stop()
+	deleteProbeData(p.Name)
+}
+
+/*
+	Forget the last stats, containers, alerts and image history of a
+	deleted probe (its monitor must be stopped)
+*/
+func deleteProbeData(name string) {
+	ProbeLastStatsMutex.Lock()
+	delete(ProbeLastStats, name)
+	ProbeLastStatsMutex.Unlock()
+
+	containers, _ := GetContainersByProbe(name)
+	for _, c := range containers {
+		DeleteFlapState(name, c.ID)
+		DeleteBaselines(name, c.ID)
+	}
+	DeleteContainersByProbe(name)
+	DeleteProbeAlerts(name)
+	DeleteImageHistory(name)
+}
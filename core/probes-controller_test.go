@@ -0,0 +1,92 @@
+package core
+
+import (
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestDeleteProbe(t *testing.T) {
+	var config = Probe{Name: "probe1", URI: "http://127.0.0.1:1", ReloadTime: 0.05}
+	var event = dguard.Event{Type: EventProbeUnreachable, Target: "probe1", Probe: "probe1"}
+
+	containerList = make(map[string]*map[string]*dguard.Container)
+	ProbeLastStats = map[string][]dguard.Container{"probe1": nil}
+	alertList = make(map[string]*AlertState)
+
+	_, err := AddProbe(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := GetProbes()[0]
+
+	// The old monitor is stopped before the new one is started
+	config.ReloadTime = 0.1
+	_, err = UpdateProbe(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-old.done:
+	default:
+		t.Error("old monitor still running after an update")
+	}
+
+	InsertContainer(&dguard.Container{ID: "abc123", Hostname: "web-1", Probe: "probe1"})
+	UpdateAlertState("", event, false)
+
+	probe := GetProbes()[0]
+	err = DeleteProbe("probe1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-probe.done:
+	default:
+		t.Error("monitor still running after a delete")
+	}
+
+	// The probe, its containers and alerts are gone
+	if len(GetProbes()) != 0 {
+		t.Error("probe not deleted")
+	}
+	if _, ok := ProbeLastStats["probe1"]; ok {
+		t.Error("last stats not deleted")
+	}
+	if _, err = GetContainersByProbe("probe1"); err == nil {
+		t.Error("containers not deleted")
+	}
+	if alerts := GetAlertStates(""); len(alerts) != 0 {
+		t.Errorf("%d alerts not deleted", len(alerts))
+	}
+	if err = DeleteProbe("probe1"); err == nil || err.Error() != "Not found" {
+		t.Errorf("second delete: %v, expected Not found", err)
+	}
+}
+
+func TestProbeInfos(t *testing.T) {
+	var p = Probe{Name: "probe1", Infos: &dguard.ProbeInfos{Name: "probe1"}}
+	var done = make(chan struct{})
+
+	// The monitor writes the infos while the API reads them
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			p.setInfos(dguard.ProbeInfos{Name: "probe1", Running: true})
+			p.setRunning(false)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if infos := p.GetInfos(); infos.Name != "probe1" {
+			t.Fatalf("infos of %q, expected probe1", infos.Name)
+		}
+	}
+	<-done
+
+	p.setInfos(dguard.ProbeInfos{Name: "probe1", Running: true})
+	infos := p.GetInfos()
+	infos.Running = false
+	if !p.GetInfos().Running {
+		t.Error("infos changed through a copy")
+	}
+}
@@ -0,0 +1,371 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+/*
+	Container metrics usable in rules
+	(names are the same as the InfluxDB fields)
+*/
+const (
+	MetricCPUUsage      = "cpuusage"
+	MetricSizeMemory    = "sizememory"
+	MetricSizeRootFs    = "sizerootfs"
+	MetricSizeRw        = "sizerw"
+	MetricNetBandwithRX = "netbandwithrx"
+	MetricNetBandwithTX = "netbandwithtx"
+)
+
+/*
+	Probe metrics usable in rules
+	(diskfree and memoryfree are percentages)
+*/
+const (
+	MetricLoadAvg1        = "loadavg1"
+	MetricLoadAvg5        = "loadavg5"
+	MetricLoadAvg15       = "loadavg15"
+	MetricDiskAvailable   = "diskavailable"
+	MetricDiskFree        = "diskfree"
+	MetricMemoryAvailable = "memoryavailable"
+	MetricMemoryFree      = "memoryfree"
+)
+
+var (
+	// Event types raised by probe metrics
+	probeMetricTypes = map[string]int{
+		MetricLoadAvg1:        dguard.EventCPUUsageOverload,
+		MetricLoadAvg5:        dguard.EventCPUUsageOverload,
+		MetricLoadAvg15:       dguard.EventCPUUsageOverload,
+		MetricDiskAvailable:   dguard.EventDiskSpaceLimitReached,
+		MetricDiskFree:        dguard.EventDiskSpaceLimitReached,
+		MetricMemoryAvailable: dguard.EventMemorySpaceLimitReached,
+		MetricMemoryFree:      dguard.EventMemorySpaceLimitReached,
+	}
+)
+
+/*
+	Alert rule
+
+	A rule compares a metric of each container or probe sample with its
+	warning and critical thresholds (a threshold equal to 0 is disabled).
+	Available and free space metrics of probes are reached when they are
+	under the thresholds.
+	Probe and Container are optional regexps restricting the rule to some
+	probes or containers (matched against "hostname (id)").
+	For is the duration the condition must hold before the alert fires
+	("5m", "30s", ...) and Clear is the value under which a firing alert is
+	resolved (by default, when no threshold is reached anymore).
+*/
+type Rule struct {
+	Name        string  `yaml:"name"`
+	Metric      string  `yaml:"metric"`
+	Probe       string  `yaml:"probe"`
+	Container   string  `yaml:"container"`
+	Warning     float64 `yaml:"warning"`
+	Critical    float64 `yaml:"critical"`
+	Clear       float64 `yaml:"clear"`
+	For         string  `yaml:"for"`
+	forDuration time.Duration
+	probeRule   bool // true if the metric is a probe metric
+	below       bool // true if the thresholds are reached under them
+	probe       *regexp.Regexp
+	container   *regexp.Regexp
+}
+
+/*
+	Check if the rule is valid, parse its duration and compile its regexps
+*/
+func (r *Rule) Check() error {
+	var err error // Error handling
+
+	if r.Name == "" {
+		return errors.New("Rule without name")
+	}
+	if _, ok := probeMetricTypes[r.Metric]; ok {
+		r.probeRule = true
+		r.below = r.Metric == MetricDiskAvailable || r.Metric == MetricDiskFree ||
+			r.Metric == MetricMemoryAvailable || r.Metric == MetricMemoryFree
+		if r.Container != "" {
+			return errors.New("Rule " + r.Name + ": container regexp on a probe metric")
+		}
+	} else if _, _, err = ContainerMetric(&dguard.Container{}, r.Metric); err != nil {
+		return errors.New("Rule " + r.Name + ": " + err.Error())
+	}
+	if r.Warning == 0 && r.Critical == 0 {
+		return errors.New("Rule " + r.Name + ": no warning or critical threshold")
+	}
+	if r.For != "" {
+		r.forDuration, err = time.ParseDuration(r.For)
+		if err != nil {
+			return errors.New("Rule " + r.Name + ": bad duration: " + err.Error())
+		}
+	}
+	r.probe, r.container = nil, nil
+	if r.Probe != "" {
+		r.probe, err = regexp.Compile(r.Probe)
+		if err != nil {
+			return errors.New("Rule " + r.Name + ": bad probe regexp: " + err.Error())
+		}
+	}
+	if r.Container != "" {
+		r.container, err = regexp.Compile(r.Container)
+		if err != nil {
+			return errors.New("Rule " + r.Name + ": bad container regexp: " + err.Error())
+		}
+	}
+	return nil
+}
+
+/*
+	Check if the rule applies to a container of a probe
+*/
+func (r *Rule) Match(probeName string, target string) bool {
+	if r.probe != nil && !r.probe.MatchString(probeName) {
+		return false
+	}
+	if r.container != nil && !r.container.MatchString(target) {
+		return false
+	}
+	return true
+}
+
+/*
+	Return the severity reached by a value, -1 if no threshold is reached
+*/
+func (r *Rule) Severity(value float64) int {
+	if r.below {
+		if r.Critical != 0 && value <= r.Critical {
+			return dguard.EventCritical
+		}
+		if r.Warning != 0 && value <= r.Warning {
+			return dguard.EventWarning
+		}
+		return -1
+	}
+	if r.Critical != 0 && value >= r.Critical {
+		return dguard.EventCritical
+	}
+	if r.Warning != 0 && value >= r.Warning {
+		return dguard.EventWarning
+	}
+	return -1
+}
+
+/*
+	Check if a firing alert must be resolved by a value
+*/
+func (r *Rule) Cleared(value float64) bool {
+	if r.Clear != 0 {
+		if r.below {
+			return value > r.Clear
+		}
+		return value < r.Clear
+	}
+	return r.Severity(value) == -1
+}
+
+/*
+	Get a metric value of a container and the event type raised by this metric
+*/
+func ContainerMetric(c *dguard.Container, metric string) (float64, int, error) {
+	switch metric {
+	case MetricCPUUsage:
+		return float64(c.CPUUsage), dguard.EventCPUUsageOverload, nil
+	case MetricSizeMemory:
+		return float64(c.MemoryUsed), dguard.EventMemorySpaceLimitReached, nil
+	case MetricSizeRootFs:
+		return float64(c.SizeRootFs), dguard.EventDiskSpaceLimitReached, nil
+	case MetricSizeRw:
+		return float64(c.SizeRw), dguard.EventDiskSpaceLimitReached, nil
+	case MetricNetBandwithRX:
+		return float64(c.NetBandwithRX), dguard.EventNetBandwithOverload, nil
+	case MetricNetBandwithTX:
+		return float64(c.NetBandwithTX), dguard.EventNetBandwithOverload, nil
+	}
+	return 0, 0, errors.New("Unknown metric: " + metric)
+}
+
+/*
+	Get a metric value of a probe and the event type raised by this metric
+*/
+func ProbeMetric(infos *dguard.ProbeInfos, metric string) (float64, int, error) {
+	var eventType, ok = probeMetricTypes[metric]
+	if !ok {
+		return 0, 0, errors.New("Unknown metric: " + metric)
+	}
+
+	switch metric {
+	case MetricLoadAvg1, MetricLoadAvg5, MetricLoadAvg15:
+		// LoadAvg is like "0.53,0.68,0.66"
+		var loads = strings.Split(infos.LoadAvg, ",")
+		var i = 0
+		if metric == MetricLoadAvg5 {
+			i = 1
+		} else if metric == MetricLoadAvg15 {
+			i = 2
+		}
+		if len(loads) != 3 {
+			return 0, 0, errors.New("Can't parse load average: " + infos.LoadAvg)
+		}
+		value, err := utils.S2F(strings.TrimSpace(loads[i]))
+		if err != nil {
+			return 0, 0, errors.New("Can't parse load average: " + infos.LoadAvg)
+		}
+		return value, eventType, nil
+	case MetricDiskAvailable:
+		return float64(infos.DiskAvailable), eventType, nil
+	case MetricDiskFree:
+		if infos.DiskTotal == 0 {
+			return 0, 0, errors.New("Disk total is 0")
+		}
+		return float64(infos.DiskAvailable) / float64(infos.DiskTotal) * 100, eventType, nil
+	case MetricMemoryAvailable:
+		return float64(infos.MemoryAvailable), eventType, nil
+	}
+
+	// MetricMemoryFree
+	if infos.MemoryTotal == 0 {
+		return 0, 0, errors.New("Memory total is 0")
+	}
+	return float64(infos.MemoryAvailable) / float64(infos.MemoryTotal) * 100, eventType, nil
+}
+
+/*
+	Evaluate a rule on a metric value and send alerts
+
+	An alert is sent when a threshold is reached during the rule duration
+	(the alerts controller handles duplicates and re-notifications). A
+	resolved event is sent when the firing alert is cleared. Each rule has
+	its own alert state, even if other rules raise the same event type.
+*/
+func (r *Rule) evaluate(event dguard.Event, value float64) {
+	var source = "rule:" + r.Name
+	var severity = r.Severity(value)
+	var state, ok = GetAlertState(source, event)
+
+	event.Data = fmt.Sprintf("%s: %.2f (warning: %.2f, critical: %.2f)",
+		r.Metric, value, r.Warning, r.Critical)
+
+	// Firing alert: resolve it or send it again
+	if ok && state.Status == AlertFiring {
+		if r.Cleared(value) {
+			l.Debug("Rule", r.Name, "cleared by", event.Target, "("+event.Probe+"):", value)
+			ResolveAlertFrom(source, event)
+			return
+		}
+		// Between clear and warning thresholds, keep the alert as a warning
+		if severity == -1 {
+			severity = dguard.EventWarning
+		}
+		event.Severity = severity
+		AlertFrom(source, event)
+		return
+	}
+
+	// No threshold reached: forget pending alert
+	if severity == -1 {
+		if ok && state.Status == AlertPending {
+			DeletePendingAlert(source, event)
+		}
+		return
+	}
+
+	// Wait until the condition held during the rule duration
+	event.Severity = severity
+	if time.Since(SetAlertPending(source, event)) < r.forDuration {
+		l.Debug("Rule", r.Name, "pending for", event.Target, "("+event.Probe+"):", value)
+		return
+	}
+
+	l.Debug("Rule", r.Name, "reached by", event.Target, "("+event.Probe+"):", value)
+	AlertFrom(source, event)
+}
+
+/*
+	Resolve the firing alerts of the rules which don't exist anymore (nothing
+	would evaluate them again), and forget their pending alerts
+*/
+func ResolveRemovedRulesAlerts() {
+	var sources = make(map[string]bool) // Sources of the existing rules
+
+	for _, r := range GetConfig().DockerGuard.Rules {
+		sources["rule:"+r.Name] = true
+	}
+
+	for _, a := range GetAlertStates("") {
+		if !strings.HasPrefix(a.Source, "rule:") || sources[a.Source] {
+			continue
+		}
+		name := strings.TrimPrefix(a.Source, "rule:")
+		switch a.Status {
+		case AlertFiring:
+			l.Info("Rule", name, "removed, resolving its alert for", a.Target, "("+a.Probe+")")
+			event := a.Event
+			event.Data = "Rule " + name + " removed"
+			ResolveAlertFrom(a.Source, event)
+		case AlertPending:
+			DeletePendingAlert(a.Source, a.Event)
+		}
+	}
+}
+
+/*
+	Evaluate rules on a container sample
+*/
+func CheckContainerRules(probeName string, c *dguard.Container) {
+	var rules = GetConfig().DockerGuard.Rules
+	var target = c.Hostname + " (" + c.ID + ")"
+
+	// Don't keep states of unwatched containers
+	if !Watched(dguard.Event{Target: target, Probe: probeName}) {
+		return
+	}
+
+	for i := range rules {
+		var r = &rules[i]
+
+		if r.probeRule || !r.Match(probeName, target) {
+			continue
+		}
+
+		value, eventType, err := ContainerMetric(c, r.Metric)
+		if err != nil {
+			l.Error("CheckContainerRules ("+probeName+"):", err)
+			continue
+		}
+
+		r.evaluate(dguard.Event{Type: eventType, Target: target, Probe: probeName}, value)
+	}
+}
+
+/*
+	Evaluate rules on a probe sample
+*/
+func CheckProbeRules(probeName string, infos *dguard.ProbeInfos) {
+	var rules = GetConfig().DockerGuard.Rules
+
+	for i := range rules {
+		var r = &rules[i]
+
+		if !r.probeRule || !r.Match(probeName, probeName) {
+			continue
+		}
+
+		value, eventType, err := ProbeMetric(infos, r.Metric)
+		if err != nil {
+			l.Error("CheckProbeRules ("+probeName+"):", err)
+			continue
+		}
+
+		r.evaluate(dguard.Event{Type: eventType, Target: probeName, Probe: probeName}, value)
+	}
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Step of a rule test: evaluate a value after a delay, and check the alert
+	status ("" if there is no alert) and severity
+*/
+type ruleStep struct {
+	value    float64
+	elapsed  time.Duration
+	status   string
+	severity int
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	var tests = []struct {
+		name  string
+		rule  Rule
+		steps []ruleStep
+	}{
+		{"fires at once without duration",
+			Rule{Name: "cpu", Metric: MetricCPUUsage, Warning: 80, Critical: 95},
+			[]ruleStep{
+				{50, 0, "", 0},
+				{85, 0, AlertFiring, dguard.EventWarning},
+				{97, 0, AlertFiring, dguard.EventCritical},
+				{50, 0, AlertResolved, dguard.EventCritical},
+			}},
+		{"pending until the duration is sustained",
+			Rule{Name: "cpu", Metric: MetricCPUUsage, Warning: 80, For: "5m"},
+			[]ruleStep{
+				{85, 0, AlertPending, dguard.EventWarning},
+				{85, 2 * time.Minute, AlertPending, dguard.EventWarning},
+				{85, 4 * time.Minute, AlertFiring, dguard.EventWarning},
+			}},
+		{"pending alert forgotten when the value goes down",
+			Rule{Name: "cpu", Metric: MetricCPUUsage, Warning: 80, For: "5m"},
+			[]ruleStep{
+				{85, 0, AlertPending, dguard.EventWarning},
+				{50, 0, "", 0},
+				{85, 4 * time.Minute, AlertPending, dguard.EventWarning},
+			}},
+		{"firing until the clear threshold",
+			Rule{Name: "cpu", Metric: MetricCPUUsage, Warning: 80, Critical: 95, Clear: 60},
+			[]ruleStep{
+				{97, 0, AlertFiring, dguard.EventCritical},
+				{70, 0, AlertFiring, dguard.EventWarning},
+				{59, 0, AlertResolved, dguard.EventWarning},
+			}},
+		{"probe metric reached under the thresholds",
+			Rule{Name: "disk", Metric: MetricDiskAvailable, Warning: 1000, Critical: 100, Clear: 2000},
+			[]ruleStep{
+				{5000, 0, "", 0},
+				{500, 0, AlertFiring, dguard.EventWarning},
+				{50, 0, AlertFiring, dguard.EventCritical},
+				{1500, 0, AlertFiring, dguard.EventWarning},
+				{2500, 0, AlertResolved, dguard.EventWarning},
+			}},
+	}
+
+	for _, test := range tests {
+		var event = dguard.Event{Type: dguard.EventCPUUsageOverload, Target: "probe1", Probe: "probe1"}
+		var source = "rule:" + test.rule.Name
+
+		alertList = make(map[string]*AlertState)
+		err := test.rule.Check()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		for i, step := range test.steps {
+			// Move the pending alert back in time
+			if a, ok := alertList[alertKey(event.Type, event.Probe, event.Target, source)]; ok && a.Status == AlertPending {
+				a.Since = a.Since.Add(-step.elapsed)
+			}
+
+			test.rule.evaluate(event, step.value)
+
+			a, ok := GetAlertState(source, event)
+			if !ok {
+				if step.status != "" {
+					t.Errorf("%s: step %d: no alert, expected %s", test.name, i, step.status)
+				}
+				continue
+			}
+			if a.Status != step.status || a.Severity != step.severity {
+				t.Errorf("%s: step %d: alert %s (severity %d), expected %s (severity %d)",
+					test.name, i, a.Status, a.Severity, step.status, step.severity)
+			}
+		}
+	}
+}
+
+func TestRulesKeepTheirOwnState(t *testing.T) {
+	var rootfs = Rule{Name: "rootfs", Metric: MetricSizeRootFs, Warning: 1000}
+	var rw = Rule{Name: "rw", Metric: MetricSizeRw, Warning: 1000}
+	var event = dguard.Event{Type: dguard.EventDiskSpaceLimitReached, Target: "db-1 (abc123)", Probe: "probe1"}
+
+	// Watch every container
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+	})()
+
+	alertList = make(map[string]*AlertState)
+	rootfs.Check()
+	rw.Check()
+
+	// rw is low, but it must not resolve the alert of rootfs
+	rootfs.evaluate(event, 2000)
+	rw.evaluate(event, 10)
+
+	a, ok := GetAlertState("rule:rootfs", event)
+	if !ok || a.Status != AlertFiring {
+		t.Errorf("rootfs alert = %+v, expected firing", a)
+	}
+	if _, ok = GetAlertState("rule:rw", event); ok {
+		t.Error("rw alert exists, expected none")
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	var tests = []struct {
+		name  string
+		rule  Rule
+		probe string
+		match bool
+	}{
+		{"no regexp", Rule{}, "prod-1", true},
+		{"probe matched", Rule{Probe: "^prod-"}, "prod-1", true},
+		{"probe not matched", Rule{Probe: "^dev-"}, "prod-1", false},
+		{"container matched", Rule{Container: "^db-"}, "prod-1", true},
+		{"container not matched", Rule{Container: "^web-"}, "prod-1", false},
+	}
+
+	for _, test := range tests {
+		test.rule.Name = "cpu"
+		test.rule.Metric = MetricCPUUsage
+		test.rule.Warning = 80
+		err := test.rule.Check()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if match := test.rule.Match(test.probe, "db-1 (abc123)"); match != test.match {
+			t.Errorf("%s: Match = %v, expected %v", test.name, match, test.match)
+		}
+	}
+
+	var bad = Rule{Name: "cpu", Metric: MetricCPUUsage, Warning: 80, Container: "db("}
+	if err := bad.Check(); err == nil {
+		t.Error("bad container regexp accepted")
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+const (
+	// Severity of the dropped events
+	SeverityDrop = -1
+)
+
+/*
+	Severity rule
+
+	The severity of the events of type Type, whose probe and target match
+	the Probe and Container regexps (empty = every event), is replaced by
+	Severity: "notice", "warning", "critical" or "drop" to never send them.
+	The Container regexp doesn't match events targeting a probe.
+*/
+type SeverityRule struct {
+	Type      string `yaml:"type"`
+	Probe     string `yaml:"probe"`
+	Container string `yaml:"container"`
+	Severity  string `yaml:"severity"`
+	eventType int
+	severity  int
+	probe     *regexp.Regexp
+	container *regexp.Regexp
+}
+
+/*
+	Check if the severity rule is valid and compile its regexps
+*/
+func (r *SeverityRule) Check() error {
+	var err error // Error handling
+
+	r.eventType, err = EventTypeFromString(r.Type)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(r.Severity, "drop") {
+		r.severity = SeverityDrop
+	} else {
+		r.severity, err = SeverityFromString(r.Severity)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.probe, r.container = nil, nil
+	if r.Probe != "" {
+		r.probe, err = regexp.Compile(r.Probe)
+		if err != nil {
+			return errors.New("Bad probe regexp: " + err.Error())
+		}
+	}
+	if r.Container != "" {
+		r.container, err = regexp.Compile(r.Container)
+		if err != nil {
+			return errors.New("Bad container regexp: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+/*
+	Check if the severity rule applies to an event
+*/
+func (r *SeverityRule) Match(event dguard.Event) bool {
+	if event.Type != r.eventType {
+		return false
+	}
+	if r.probe != nil && !r.probe.MatchString(event.Probe) {
+		return false
+	}
+	if r.container != nil && (event.Target == event.Probe || !r.container.MatchString(event.Target)) {
+		return false
+	}
+	return true
+}
+
+/*
+	Return the severity of an event according to the severity rules
+	(the first matching rule is used, SeverityDrop if the event is dropped)
+*/
+func EventSeverity(event dguard.Event) int {
+	var severities = GetConfig().DockerGuard.Event.Severities
+
+	for i := range severities {
+		if severities[i].Match(event) {
+			return severities[i].severity
+		}
+	}
+	return event.Severity
+}
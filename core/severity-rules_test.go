@@ -0,0 +1,87 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Check the severity rules of a test config
+*/
+func severityRules(t *testing.T, rules ...SeverityRule) []SeverityRule {
+	for i := range rules {
+		if err := rules[i].Check(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rules
+}
+
+func TestEventSeverity(t *testing.T) {
+	var container = dguard.Event{Severity: dguard.EventWarning, Type: EventContainerFlapping, Target: "db-1 (abc123)", Probe: "prod-1"}
+	var probe = dguard.Event{Severity: dguard.EventCritical, Type: EventProbeUnreachable, Target: "dev-1", Probe: "dev-1"}
+
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.Severities = severityRules(t,
+			SeverityRule{Type: "ContainerFlapping", Container: "^web-", Severity: "drop"},
+			SeverityRule{Type: "ContainerFlapping", Probe: "^prod-", Severity: "critical"},
+			SeverityRule{Type: "ContainerFlapping", Severity: "notice"},
+			SeverityRule{Type: "ProbeUnreachable", Container: ".*", Severity: "notice"},
+			SeverityRule{Type: "ProbeUnreachable", Probe: "^dev-", Severity: "WARNING"},
+		)
+	})()
+
+	var tests = []struct {
+		name     string
+		event    dguard.Event
+		severity int
+	}{
+		{"first matching rule", container, dguard.EventCritical},
+		{"container rule", dguard.Event{Type: EventContainerFlapping, Target: "web-1 (def456)", Probe: "prod-1"}, SeverityDrop},
+		{"rule without regexp", dguard.Event{Type: EventContainerFlapping, Target: "db-1 (abc123)", Probe: "dev-1"}, dguard.EventNotice},
+		{"container rule ignored for probe events", probe, dguard.EventWarning},
+		{"no rule", dguard.Event{Severity: dguard.EventNotice, Type: EventContainerReplaced, Target: "db-1 (abc123)", Probe: "prod-1"}, dguard.EventNotice},
+	}
+
+	for _, test := range tests {
+		if severity := EventSeverity(test.event); severity != test.severity {
+			t.Errorf("%s: severity %d, expected %d", test.name, severity, test.severity)
+		}
+	}
+
+	if err := (&SeverityRule{Type: "ContainerFlapping", Severity: "loud"}).Check(); err == nil {
+		t.Error("unknown severity accepted")
+	}
+}
+
+func TestAlertFromRecordsSeverity(t *testing.T) {
+	var event = dguard.Event{Severity: dguard.EventWarning, Type: EventContainerFlapping, Target: "db-1 (abc123)", Probe: "prod-1"}
+	var dropped = dguard.Event{Severity: dguard.EventWarning, Type: EventContainerFlapping, Target: "web-1 (def456)", Probe: "prod-1"}
+
+	defer changeConfig(func(c *Config) {
+		c.DockerGuard.Event.watch = []*regexp.Regexp{regexp.MustCompile(".*")}
+		c.DockerGuard.Event.Severities = severityRules(t,
+			SeverityRule{Type: "ContainerFlapping", Container: "^web-", Severity: "drop"},
+			SeverityRule{Type: "ContainerFlapping", Severity: "critical"},
+		)
+	})()
+	alertList = make(map[string]*AlertState)
+	drainEvents()
+
+	// The event is recorded with its severity, the dropped event isn't
+	AlertFrom("", event)
+	AlertFrom("", dropped)
+	events := drainEvents()
+	if len(events) != 1 {
+		t.Fatalf("%d events recorded, expected 1", len(events))
+	}
+	if events[0].Target != event.Target || events[0].Severity != dguard.EventCritical {
+		t.Errorf("recorded %s with severity %d, expected %s with severity %d",
+			events[0].Target, events[0].Severity, event.Target, dguard.EventCritical)
+	}
+	if _, ok := GetAlertState("", dropped); ok {
+		t.Error("dropped event has an alert state")
+	}
+}
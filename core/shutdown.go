@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Max duration of a graceful shutdown
+	ShutdownTimeout = 30 * time.Second
+)
+
+var (
+	// Canceled when Docker Guard Monitoring is stopping
+	shutdownCtx, stopAll = context.WithCancel(context.Background())
+)
+
+/*
+	Wait for SIGTERM or SIGINT, and shut down gracefully
+*/
+func WaitShutdown() {
+	var signals = make(chan os.Signal, 1)
+
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	l.Info("Signal", sig, "received, shutting down")
+
+	Shutdown()
+}
+
+/*
+	Stop Docker Guard Monitoring: stop accepting API requests, stop the
+	probe monitors and the detectors, send the queued alerts, write the
+	events history and save the containers and alerts states
+*/
+func Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting API requests
+	err := StopHTTPServer(ctx)
+	if err != nil {
+		l.Error("Shutdown: Can't stop the API:", err)
+	}
+
+	// Stop the probe monitors and the detectors
+	stopAll()
+	if !waitGroup(ctx, &probesWaitGroup) {
+		l.Warn("Shutdown: probe monitors still running")
+	}
+
+	// Send the queued alerts and the email digests
+	StopDispatcher(ctx)
+	CloseTransports(ctx, GetTransports())
+
+	// Write the events history
+	err = FlushEventHistory(ctx)
+	if err != nil {
+		l.Error("Shutdown: Can't write events history:", err)
+	}
+
+	// Save states
+	err = SaveListToFile()
+	if err != nil {
+		l.Error("Shutdown:", err)
+	}
+	err = SaveAlertsToFile()
+	if err != nil {
+		l.Error("Shutdown:", err)
+	}
+
+	l.Info("Shutdown complete")
+}
+
+/*
+	Wait for a WaitGroup until ctx is done
+	(return false if ctx is done first)
+*/
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) bool {
+	var done = make(chan bool)
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
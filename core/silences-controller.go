@@ -0,0 +1,267 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+
+	"../utils"
+)
+
+const (
+	// File to store silenceList
+	SilenceListFilePath = "./silences.json"
+)
+
+/*
+	Silence
+
+	Alerts matching the Probe, Container and Type regexps (empty = all)
+	are not sent between Start and End. Container is matched against the
+	target "hostname (id)", a silence with a Container regexp doesn't match
+	alerts targeting a probe.
+*/
+type Silence struct {
+	ID        string
+	Probe     string
+	Container string
+	Type      string
+	Start     time.Time
+	End       time.Time
+	Comment   string
+	CreatedBy string
+	probe     *regexp.Regexp
+	container *regexp.Regexp
+	eventType *regexp.Regexp
+}
+
+var (
+	// map[SILENCE_ID] => Silence
+	silenceList map[string]*Silence
+	// silenceList's Mutex
+	SilenceListMutex sync.Mutex
+)
+
+/*
+	Initialize silences controller
+*/
+func InitSilencesController() {
+	// Make map
+	silenceList = make(map[string]*Silence)
+
+	// Check if SilenceListFilePath exists
+	if utils.FileExists(SilenceListFilePath) {
+		// Load silenceList from the file
+		err := LoadSilencesFromFile()
+		if err != nil {
+			l.Critical("Can't load silences list from file:", err)
+		}
+	}
+}
+
+/*
+	Load silenceList from a file
+*/
+func LoadSilencesFromFile() error {
+	// Lock / Unlock silenceList
+	SilenceListMutex.Lock()
+	defer SilenceListMutex.Unlock()
+
+	// Read the file
+	content, err := utils.FileReadAllBytes(SilenceListFilePath)
+	if err != nil {
+		return errors.New("LoadSilencesFromFile: Failed to read list in file: " + err.Error())
+	}
+
+	// Parse the file
+	err = json.Unmarshal(content, &silenceList)
+	if err != nil {
+		return errors.New("LoadSilencesFromFile: Failed to unmarshal struct: " + err.Error())
+	}
+
+	// Compile regexps
+	for id, s := range silenceList {
+		err = s.compile()
+		if err != nil {
+			return errors.New("LoadSilencesFromFile: Silence " + id + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+/*
+	Save silenceList to a file
+	(silenceList must be locked by the caller)
+*/
+func saveSilencesToFile() error {
+	// Forget expired silences
+	for id, s := range silenceList {
+		if time.Now().After(s.End) {
+			delete(silenceList, id)
+		}
+	}
+
+	// silenceList => json
+	tmpJSON, err := json.Marshal(silenceList)
+	if err != nil {
+		return errors.New("SaveSilencesToFile: Failed to marshal struct: " + err.Error())
+	}
+
+	// Write json to file
+	err = utils.FileWriteAllBytes(SilenceListFilePath, tmpJSON)
+	if err != nil {
+		return errors.New("SaveSilencesToFile: Failed to write list in file: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+	Compile the regexps of the silence
+*/
+func (s *Silence) compile() error {
+	for _, f := range []struct {
+		rgxp  string
+		field **regexp.Regexp
+	}{
+		{s.Probe, &s.probe},
+		{s.Container, &s.container},
+		{s.Type, &s.eventType},
+	} {
+		*f.field = nil
+		if f.rgxp == "" {
+			continue
+		}
+		rgxp, err := regexp.Compile(f.rgxp)
+		if err != nil {
+			return errors.New("Bad regexp: " + err.Error())
+		}
+		*f.field = rgxp
+	}
+	return nil
+}
+
+/*
+	Check if the silence is valid and compile its regexps
+*/
+func (s *Silence) Check() error {
+	if err := s.compile(); err != nil {
+		return err
+	}
+	if !s.End.After(s.Start) {
+		return errors.New("Bad end: the silence must end after its start")
+	}
+	if !s.End.After(time.Now()) {
+		return errors.New("Bad end: the silence is already over")
+	}
+	return nil
+}
+
+/*
+	Check if the silence is active and matches an event
+*/
+func (s *Silence) Match(event dguard.Event, now time.Time) bool {
+	if now.Before(s.Start) || now.After(s.End) {
+		return false
+	}
+	if s.probe != nil && !s.probe.MatchString(event.Probe) {
+		return false
+	}
+	if s.container != nil && (event.Target == event.Probe || !s.container.MatchString(event.Target)) {
+		return false
+	}
+	if s.eventType != nil && !s.eventType.MatchString(EventTypeToString(event)) {
+		return false
+	}
+	return true
+}
+
+/*
+	Add a silence
+*/
+func InsertSilence(s Silence) (Silence, error) {
+	var id = make([]byte, 8)
+
+	err := s.Check()
+	if err != nil {
+		return s, err
+	}
+
+	// Make ID
+	_, err = rand.Read(id)
+	if err != nil {
+		return s, errors.New("InsertSilence: Can't make ID: " + err.Error())
+	}
+	s.ID = hex.EncodeToString(id)
+
+	// Lock / Unlock silenceList
+	SilenceListMutex.Lock()
+	defer SilenceListMutex.Unlock()
+
+	silenceList[s.ID] = &s
+	saveSilencesToFile()
+
+	return s, nil
+}
+
+/*
+	Delete a silence
+*/
+func DeleteSilence(id string) error {
+	// Lock / Unlock silenceList
+	SilenceListMutex.Lock()
+	defer SilenceListMutex.Unlock()
+
+	if _, ok := silenceList[id]; !ok {
+		return errors.New("Not found")
+	}
+	delete(silenceList, id)
+
+	return saveSilencesToFile()
+}
+
+/*
+	Get the list of active and future silences
+*/
+func GetSilences() []Silence {
+	var silences []Silence // Silences to return
+	var now = time.Now()
+
+	// Lock / Unlock silenceList
+	SilenceListMutex.Lock()
+	defer SilenceListMutex.Unlock()
+
+	for _, s := range silenceList {
+		if now.Before(s.End) {
+			silences = append(silences, *s)
+		}
+	}
+
+	return silences
+}
+
+/*
+	Check if an event is silenced
+*/
+func Silenced(event dguard.Event) bool {
+	var now = time.Now()
+
+	// Lock / Unlock silenceList
+	SilenceListMutex.Lock()
+	defer SilenceListMutex.Unlock()
+
+	for _, s := range silenceList {
+		if s.Match(event, now) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestSilenceMatch(t *testing.T) {
+	var now = time.Now()
+	var container = dguard.Event{Type: EventContainerFlapping, Probe: "probe-1", Target: "web (abc123)"}
+	var probe = dguard.Event{Type: EventProbeUnreachable, Probe: "probe-1", Target: "probe-1"}
+	var tests = []struct {
+		name    string
+		silence Silence
+		event   dguard.Event
+		match   bool
+	}{
+		{"empty silence matches everything",
+			Silence{Start: now.Add(-time.Minute), End: now.Add(time.Hour)}, container, true},
+		{"not started yet",
+			Silence{Start: now.Add(time.Minute), End: now.Add(time.Hour)}, container, false},
+		{"already over",
+			Silence{Start: now.Add(-time.Hour), End: now.Add(-time.Minute)}, container, false},
+		{"probe regexp",
+			Silence{Probe: "^probe-", Start: now, End: now.Add(time.Hour)}, container, true},
+		{"other probe",
+			Silence{Probe: "^probe-2$", Start: now, End: now.Add(time.Hour)}, container, false},
+		{"container regexp",
+			Silence{Container: "^web ", Start: now, End: now.Add(time.Hour)}, container, true},
+		{"container regexp doesn't match probe alerts",
+			Silence{Container: ".*", Start: now, End: now.Add(time.Hour)}, probe, false},
+		{"type regexp",
+			Silence{Type: "Flapping", Start: now, End: now.Add(time.Hour)}, container, true},
+		{"other type",
+			Silence{Type: "^ProbeUnreachable$", Start: now, End: now.Add(time.Hour)}, container, false},
+	}
+
+	for _, test := range tests {
+		if err := test.silence.compile(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if match := test.silence.Match(test.event, now); match != test.match {
+			t.Errorf("%s: Match() = %v, want %v", test.name, match, test.match)
+		}
+	}
+}
+
+func TestSilenceCheck(t *testing.T) {
+	var now = time.Now()
+	var tests = []struct {
+		name    string
+		silence Silence
+		valid   bool
+	}{
+		{"valid", Silence{Probe: "^probe-", Start: now, End: now.Add(time.Hour)}, true},
+		{"future", Silence{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}, true},
+		{"bad regexp", Silence{Type: "(", Start: now, End: now.Add(time.Hour)}, false},
+		{"end before start", Silence{Start: now, End: now.Add(-time.Second)}, false},
+		{"already over", Silence{Start: now.Add(-time.Hour), End: now.Add(-time.Minute)}, false},
+	}
+
+	for _, test := range tests {
+		err := test.silence.Check()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: Check() = %v", test.name, err)
+		}
+		if err != nil && !strings.HasPrefix(err.Error(), "Bad") {
+			t.Errorf("%s: Check() = %v, want a \"Bad ...\" error", test.name, err)
+		}
+	}
+}
+
+func TestSilenced(t *testing.T) {
+	var event = dguard.Event{Type: EventContainerFlapping, Probe: "probe-1", Target: "web (abc123)"}
+
+	silenceList = make(map[string]*Silence)
+
+	if Silenced(event) {
+		t.Fatal("event silenced without silence")
+	}
+	_, err := InsertSilence(Silence{Probe: "^probe-2$", Start: time.Now(), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Silenced(event) {
+		t.Fatal("event silenced by a silence of another probe")
+	}
+	s, err := InsertSilence(Silence{Probe: "^probe-1$", Start: time.Now(), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Silenced(event) {
+		t.Fatal("event not silenced")
+	}
+	err = DeleteSilence(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Silenced(event) {
+		t.Fatal("event silenced after the silence was deleted")
+	}
+}
+
+func TestLoadSilences(t *testing.T) {
+	var event = dguard.Event{Type: EventContainerFlapping, Probe: "probe-1", Target: "web (abc123)"}
+
+	silenceList = make(map[string]*Silence)
+	_, err := InsertSilence(Silence{Probe: "^probe-2$", Start: time.Now(), End: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The regexps of the loaded silences are compiled
+	err = LoadSilencesFromFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Silenced(event) {
+		t.Fatal("event silenced by a loaded silence of another probe")
+	}
+}
+
+func TestSilencesCreatePast(t *testing.T) {
+	silenceList = make(map[string]*Silence)
+
+	var body = `{"Start": "` + time.Now().Add(-2*time.Hour).Format(time.RFC3339) + `", "Duration": "1h"}`
+	var w = httptest.NewRecorder()
+	HTTPHandlerSilencesCreate(w, httptest.NewRequest("POST", "/silences", strings.NewReader(body)))
+	if w.Code != 400 {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if len(GetSilences()) != 0 {
+		t.Fatal("past silence inserted")
+	}
+}
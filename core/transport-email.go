@@ -0,0 +1,260 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+const (
+	// Max number of events kept for a digest (the oldest are dropped)
+	EmailDigestMaxEvents = 1000
+)
+
+var (
+	// Line breaks are removed from the headers (no header injection)
+	headerReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+)
+
+/*
+	Email transport
+
+	Send the event by email with a SMTP relay. In digest mode, events are
+	collected during the digest duration and sent in one summary mail.
+*/
+type EmailTransport struct {
+	name    string
+	addr    string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	digest  time.Duration
+	events  []EventMessage       // Events waiting for the digest
+	closed  bool                 // True once the transport is closed
+	mutex   sync.Mutex           // events's and closed's Mutex
+	stop    chan context.Context // Stops the digest loop (context of the last digest)
+	done    chan bool            // Closed when the digest loop is stopped
+	timeout time.Duration        // Timeout of the digest mails
+}
+
+/*
+	Make an email transport
+*/
+func NewEmailTransport(c TransportConfig, timeout time.Duration) (*EmailTransport, error) {
+	var t EmailTransport
+
+	if c.SMTPHost == "" {
+		return nil, errors.New("Transport " + c.Name + ": no smtp-host")
+	}
+	if c.From == "" || len(c.To) < 1 {
+		return nil, errors.New("Transport " + c.Name + ": no from or to address")
+	}
+	if c.SMTPPort == 0 {
+		c.SMTPPort = 25
+	}
+
+	t.name = c.Name
+	t.timeout = timeout
+	t.addr = fmt.Sprintf("%s:%d", c.SMTPHost, c.SMTPPort)
+	t.from = c.From
+	t.to = c.To
+	if c.SMTPUser != "" {
+		t.auth = smtp.PlainAuth("", c.SMTPUser, c.SMTPPassword, c.SMTPHost)
+	}
+	if c.Digest != "" {
+		digest, err := time.ParseDuration(c.Digest)
+		if err != nil || digest <= 0 {
+			return nil, errors.New("Transport " + c.Name + ": bad digest duration: " + c.Digest)
+		}
+		t.digest = digest
+		t.stop = make(chan context.Context)
+		t.done = make(chan bool)
+		go t.digestLoop()
+	}
+
+	return &t, nil
+}
+
+/*
+	Return the transport name
+*/
+func (t *EmailTransport) Name() string {
+	return t.name
+}
+
+/*
+	Send the event by email
+*/
+func (t *EmailTransport) Send(ctx context.Context, event EventMessage) error {
+	var body bytes.Buffer
+
+	// Digest mode: keep the event for the next digest
+	if t.digest != 0 {
+		t.mutex.Lock()
+		if t.closed {
+			t.mutex.Unlock()
+			return errors.New("Transport " + t.name + " is closed")
+		}
+		t.events = append(t.events, event)
+		if len(t.events) > EmailDigestMaxEvents {
+			t.events = t.events[len(t.events)-EmailDigestMaxEvents:]
+		}
+		t.mutex.Unlock()
+		return nil
+	}
+
+	fmt.Fprintf(&body, "Time:         %s\r\n", event.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&body, "Severity:     %s\r\n", event.SeverityName)
+	fmt.Fprintf(&body, "Type:         %s\r\n", event.Type)
+	fmt.Fprintf(&body, "Target:       %s\r\n", event.Target)
+	fmt.Fprintf(&body, "Target_probe: %s\r\n", event.Probe)
+	fmt.Fprintf(&body, "Data:         %s\r\n", event.Data)
+
+	subject := "[Docker Guard] " + event.SeverityName + " " + event.Type + ": " + event.Target
+
+	return t.sendMail(ctx, subject, body.String())
+}
+
+/*
+	Send the digest mail periodically, and a last one when the transport
+	is closed (within the context received from Close)
+*/
+func (t *EmailTransport) digestLoop() {
+	var ticker = time.NewTicker(t.digest)
+	var stopped bool
+	defer ticker.Stop()
+	defer close(t.done)
+
+	for !stopped {
+		var ctx = context.Background()
+
+		select {
+		case <-ticker.C:
+		case ctx = <-t.stop:
+			stopped = true
+		}
+		err := t.SendDigest(ctx)
+		if err != nil {
+			l.Error("Error transport ("+t.name+"): digest:", err)
+		}
+	}
+}
+
+/*
+	Stop the digest loop after sending the collected events
+	(the last digest is abandoned when ctx is done)
+*/
+func (t *EmailTransport) Close(ctx context.Context) {
+	if t.stop == nil {
+		return
+	}
+	t.mutex.Lock()
+	t.closed = true
+	t.mutex.Unlock()
+
+	select {
+	case t.stop <- ctx:
+	case <-ctx.Done():
+		l.Warn("Error transport (" + t.name + "): last digest not sent before the timeout")
+		return
+	}
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		l.Warn("Error transport (" + t.name + "): last digest not sent before the timeout")
+	}
+}
+
+/*
+	Send the collected events in one mail
+	(within the transport timeout, or until ctx is done)
+*/
+func (t *EmailTransport) SendDigest(ctx context.Context) error {
+	var body bytes.Buffer
+	var counts = make(map[int]int) // Number of events by severity
+
+	// Get and reset collected events
+	t.mutex.Lock()
+	events := t.events
+	t.events = nil
+	t.mutex.Unlock()
+
+	if len(events) < 1 {
+		return nil
+	}
+
+	fmt.Fprintf(&body, "%d alerts since %s:\r\n\r\n", len(events), events[0].Time.Format(time.RFC1123Z))
+	for _, e := range events {
+		counts[e.Severity]++
+		fmt.Fprintf(&body, "[%s] %s %s: %s (%s)\r\n", e.Time.Format("2006-01-02 15:04:05"), e.SeverityName, e.Type, e.Target, e.Probe)
+		if e.Data != "" {
+			fmt.Fprintf(&body, "    %s\r\n", e.Data)
+		}
+	}
+
+	subject := fmt.Sprintf("[Docker Guard] %d alerts (%d critical, %d warning, %d notice)", len(events),
+		counts[dguard.EventCritical], counts[dguard.EventWarning], counts[dguard.EventNotice])
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	err := t.sendMail(ctx, subject, body.String())
+	if err != nil {
+		// Keep events for the next digest
+		t.mutex.Lock()
+		t.events = append(events, t.events...)
+		if len(t.events) > EmailDigestMaxEvents {
+			t.events = t.events[len(t.events)-EmailDigestMaxEvents:]
+		}
+		t.mutex.Unlock()
+	}
+
+	return err
+}
+
+/*
+	Send a mail to the recipients
+	(net/smtp can't be canceled: on timeout, the mail may still be sent)
+*/
+func (t *EmailTransport) sendMail(ctx context.Context, subject string, body string) error {
+	var msg bytes.Buffer
+	var now = time.Now()
+
+	fmt.Fprintf(&msg, "From: %s\r\n", t.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(t.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerReplacer.Replace(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%d.%d@%s>\r\n", now.UnixNano(), rand.Int63(), mailDomain(t.from))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n")
+	fmt.Fprintf(&msg, "\r\n%s", body)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(t.addr, t.auth, t.from, t.to, msg.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+	Return the domain of a mail address (for the Message-ID header)
+*/
+func mailDomain(address string) string {
+	i := strings.LastIndex(address, "@")
+	if i == -1 {
+		return "localhost"
+	}
+	return strings.TrimRight(address[i+1:], ">")
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Start a test SMTP server and return its port and a function returning
+	the received mails
+*/
+func newTestSMTP(t *testing.T) (int, func() []string) {
+	var mails []string
+	var mutex sync.Mutex
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer listener.Close()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				var data []string // Lines of the mail being received
+				var inData bool
+
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				conn.Write([]byte("220 localhost ESMTP\r\n"))
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch {
+					case inData && line == ".\r\n":
+						inData = false
+						mutex.Lock()
+						mails = append(mails, strings.Join(data, ""))
+						mutex.Unlock()
+						data = nil
+						conn.Write([]byte("250 OK\r\n"))
+					case inData:
+						data = append(data, line)
+					case strings.HasPrefix(line, "DATA"):
+						inData = true
+						conn.Write([]byte("354 Go ahead\r\n"))
+					case strings.HasPrefix(line, "QUIT"):
+						conn.Write([]byte("221 Bye\r\n"))
+						return
+					default:
+						conn.Write([]byte("250 OK\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), mails...)
+	}
+}
+
+func TestEmailHeaders(t *testing.T) {
+	port, mails := newTestSMTP(t)
+	transport, err := NewEmailTransport(TransportConfig{Name: "email", SMTPHost: "127.0.0.1", SMTPPort: port,
+		From: "dgm@example.com", To: []string{"ops@example.com"}}, DefaultTransportTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{
+		Type:   EventProbeRecovered,
+		Target: "probe1\r\nBcc: someone@example.com",
+		Probe:  "probe1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mails()) != 1 {
+		t.Fatalf("%d mails received, expected 1", len(mails()))
+	}
+	headers := strings.SplitN(mails()[0], "\r\n\r\n", 2)[0]
+	if strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("header injected by the target:\n%s", headers)
+	}
+	for _, h := range []string{"Date: ", "Message-ID: <", "@example.com>"} {
+		if !strings.Contains(headers, h) {
+			t.Errorf("no %q in the headers:\n%s", h, headers)
+		}
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	port, mails := newTestSMTP(t)
+	transport, err := NewEmailTransport(TransportConfig{Name: "digest", SMTPHost: "127.0.0.1", SMTPPort: port,
+		From: "dgm@example.com", To: []string{"ops@example.com"}, Digest: "1h"}, DefaultTransportTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The events are sent in one mail when the transport is closed
+	for _, target := range []string{"web-1", "web-2"} {
+		err = transport.Send(context.Background(), NewEventMessage(dguard.Event{Type: EventProbeRecovered, Target: target}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mails()) != 0 {
+		t.Fatal("digest sent before its interval")
+	}
+	transport.Close(context.Background())
+	if len(mails()) != 1 {
+		t.Fatalf("%d mails received, expected 1", len(mails()))
+	}
+	for _, s := range []string{"2 alerts since", "web-1", "web-2"} {
+		if !strings.Contains(mails()[0], s) {
+			t.Errorf("no %q in the digest:\n%s", s, mails()[0])
+		}
+	}
+
+	// The events sent after Close would be lost
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+	if err == nil {
+		t.Error("no error after Close")
+	}
+}
+
+func TestEmailDigestTimeout(t *testing.T) {
+	// SMTP server which never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// The digest is sent within the transport timeout
+	rt, err := NewRoutedTransport(TransportConfig{Name: "digest", Type: TransportEmail, Timeout: "200ms",
+		SMTPHost: "127.0.0.1", SMTPPort: listener.Addr().(*net.TCPAddr).Port,
+		From: "dgm@example.com", To: []string{"ops@example.com"}, Digest: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := rt.Transport.(*EmailTransport)
+	transport.Send(context.Background(), NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+	start := time.Now()
+	err = transport.SendDigest(context.Background())
+	if err == nil || time.Since(start) > time.Second {
+		t.Errorf("SendDigest returned %v after %s, expected a timeout", err, time.Since(start))
+	}
+	transport.Close(context.Background())
+}
+
+func TestEmailCloseTimeout(t *testing.T) {
+	// SMTP server which never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	transport, err := NewEmailTransport(TransportConfig{Name: "digest", SMTPHost: "127.0.0.1", SMTPPort: port,
+		From: "dgm@example.com", To: []string{"ops@example.com"}, Digest: "1h"}, DefaultTransportTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.Send(context.Background(), NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+
+	// The last digest is abandoned when the shutdown context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	transport.Close(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %s, expected at most the context timeout", elapsed)
+	}
+}
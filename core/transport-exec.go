@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+
+	"../utils"
+)
+
+/*
+	Exec transport
+
+	Exec the program at path with 5 arguments:
+	severity, type, target, target_probe and data
+*/
+type ExecTransport struct {
+	name string
+	path string
+}
+
+/*
+	Make an exec transport
+*/
+func NewExecTransport(c TransportConfig) (*ExecTransport, error) {
+	if c.Path == "" {
+		return nil, errors.New("Transport " + c.Name + ": no path")
+	}
+
+	return &ExecTransport{name: c.Name, path: c.Path}, nil
+}
+
+/*
+	Return the transport name
+*/
+func (t *ExecTransport) Name() string {
+	return t.name
+}
+
+/*
+	Exec the transport program
+	(it runs in its own process group: on timeout, the whole group is killed,
+	with the children still holding its output, like a hung curl)
+*/
+func (t *ExecTransport) Send(ctx context.Context, message EventMessage) error {
+	var out bytes.Buffer // Program output
+	var done = make(chan error, 1)
+
+	cmd := exec.Command(t.path,
+		utils.I2S(message.Severity),
+		message.Type,
+		message.Target,
+		message.Probe,
+		message.Data)
+	cmd.Stdout = &out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Don't wait for the output of the killed processes
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return ctx.Err()
+	}
+	if err != nil {
+		return errors.New(err.Error() + " / Out: " + out.String())
+	}
+	l.Debug("Transport ("+t.name+") Out:", out.String())
+
+	return nil
+}
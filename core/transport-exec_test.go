@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Write a shell script in a temporary directory and return its path
+*/
+func writeScript(t *testing.T, dir string, content string) string {
+	path := filepath.Join(dir, "transport.sh")
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecTransportArguments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dgm-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The script writes one argument per line
+	argsPath := filepath.Join(dir, "args")
+	script := writeScript(t, dir, `for a in "$@"; do echo "$a" >> `+argsPath+`; done`+"\n")
+
+	transport, err := NewExecTransport(TransportConfig{Name: "test", Path: script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{
+		Severity: dguard.EventCritical,
+		Type:     EventProbeUnreachable,
+		Target:   "probe1",
+		Probe:    "probe1",
+		Data:     "Down since now, last error: \"connection refused\"; $HOME"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(argsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	expected := []string{"2", "ProbeUnreachable", "probe1", "probe1", "Down since now, last error: \"connection refused\"; $HOME"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("arguments = %q, expected %q", got, expected)
+	}
+}
+
+func TestExecTransportError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dgm-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := writeScript(t, dir, "echo 'smtp down'\nexit 1\n")
+	transport, err := NewExecTransport(TransportConfig{Name: "test", Path: script})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+	if err == nil || !strings.Contains(err.Error(), "smtp down") {
+		t.Errorf("error = %v, expected the program output", err)
+	}
+}
+
+func TestExecTransportTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dgm-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The shell waits for a child holding its output (like a hung curl)
+	script := writeScript(t, dir, "sleep 5; echo\n")
+	transport, err := NewExecTransport(TransportConfig{Name: "test", Path: script})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = transport.Send(ctx, NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+	if err == nil {
+		t.Error("no error, expected a timeout")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Send returned after %s, expected the timeout", time.Since(start))
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Transport filter config
+
+	An event is routed to the transport if its severity is at least
+	MinSeverity ("notice", "warning" or "critical"), its type is in Types,
+	its probe matches one of the Probes regexps and its target matches one of
+	the Containers regexps (not used for events targeting a probe).
+	Empty fields match every event.
+*/
+type TransportFilterConfig struct {
+	MinSeverity string   `yaml:"min-severity"`
+	Types       []string `yaml:"types"`
+	Probes      []string `yaml:"probes"`
+	Containers  []string `yaml:"containers"`
+}
+
+/*
+	Transport filter
+*/
+type TransportFilter struct {
+	MinSeverity int
+	Types       map[int]bool
+	Probes      []*regexp.Regexp
+	Containers  []*regexp.Regexp
+}
+
+/*
+	Transport with its filter, timeout and config
+*/
+type RoutedTransport struct {
+	Transport
+	Filter  *TransportFilter
+	Timeout time.Duration
+	config  TransportConfig
+}
+
+/*
+	Return the max number of retries of an alert sent by the transport
+	(the transport's retries, else the event retries of the config)
+*/
+func (t *RoutedTransport) MaxRetries() int {
+	var retries = t.config.Retries
+
+	if retries == 0 {
+		retries = GetConfig().DockerGuard.Event.Retries
+	}
+	if retries < 0 {
+		return 0
+	} else if retries == 0 {
+		return DefaultDispatchRetries
+	}
+	return retries
+}
+
+/*
+	Make a transport filter from its config
+*/
+func NewTransportFilter(c TransportFilterConfig) (*TransportFilter, error) {
+	var f TransportFilter
+	var err error // Error handling
+
+	if c.MinSeverity != "" {
+		f.MinSeverity, err = SeverityFromString(c.MinSeverity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.Types) > 0 {
+		f.Types = make(map[int]bool)
+		for _, name := range c.Types {
+			t, err := EventTypeFromString(name)
+			if err != nil {
+				return nil, err
+			}
+			f.Types[t] = true
+		}
+	}
+
+	f.Probes, err = compileAll(c.Probes)
+	if err != nil {
+		return nil, err
+	}
+	f.Containers, err = compileAll(c.Containers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+/*
+	Compile a list of regexps
+*/
+func compileAll(rgxps []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+
+	for _, rgxp := range rgxps {
+		r, err := regexp.Compile(rgxp)
+		if err != nil {
+			return nil, errors.New("Bad regexp: " + err.Error())
+		}
+		compiled = append(compiled, r)
+	}
+
+	return compiled, nil
+}
+
+/*
+	Check if an event must be sent by the transport
+*/
+func (f *TransportFilter) Match(event dguard.Event) bool {
+	if event.Severity < f.MinSeverity {
+		return false
+	}
+	if f.Types != nil && !f.Types[event.Type] {
+		return false
+	}
+	if len(f.Probes) > 0 && !matchOne(f.Probes, event.Probe) {
+		return false
+	}
+	if len(f.Containers) > 0 && event.Target != event.Probe && !matchOne(f.Containers, event.Target) {
+		return false
+	}
+	return true
+}
+
+/*
+	Check if a string matches one of the regexps
+*/
+func matchOne(rgxps []*regexp.Regexp, s string) bool {
+	for _, rgxp := range rgxps {
+		if rgxp.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestTransportFilterMatch(t *testing.T) {
+	var container = dguard.Event{
+		Severity: dguard.EventWarning,
+		Type:     dguard.EventContainerStopped,
+		Target:   "db-1 (abc123)",
+		Probe:    "prod-1"}
+	var probe = dguard.Event{
+		Severity: dguard.EventCritical,
+		Type:     EventProbeUnreachable,
+		Target:   "prod-1",
+		Probe:    "prod-1"}
+
+	var tests = []struct {
+		name   string
+		filter TransportFilter
+		event  dguard.Event
+		match  bool
+	}{
+		{"empty filter", TransportFilter{}, container, true},
+		{"severity reached", TransportFilter{MinSeverity: dguard.EventWarning}, container, true},
+		{"severity too low", TransportFilter{MinSeverity: dguard.EventCritical}, container, false},
+		{"type listed", TransportFilter{Types: map[int]bool{dguard.EventContainerStopped: true}}, container, true},
+		{"type not listed", TransportFilter{Types: map[int]bool{dguard.EventContainerStarted: true}}, container, false},
+		{"probe matched", TransportFilter{Probes: regexps("^dev-", "^prod-")}, container, true},
+		{"probe not matched", TransportFilter{Probes: regexps("^dev-")}, container, false},
+		{"container matched", TransportFilter{Containers: regexps("^db-")}, container, true},
+		{"container not matched", TransportFilter{Containers: regexps("^web-")}, container, false},
+		{"containers ignored for probe events", TransportFilter{Containers: regexps("^web-")}, probe, true},
+		{"all fields", TransportFilter{
+			MinSeverity: dguard.EventWarning,
+			Types:       map[int]bool{EventProbeUnreachable: true},
+			Probes:      regexps("prod"),
+			Containers:  regexps("^db-")}, probe, true},
+	}
+
+	for _, test := range tests {
+		if match := test.filter.Match(test.event); match != test.match {
+			t.Errorf("%s: Match = %v, expected %v", test.name, match, test.match)
+		}
+	}
+}
+
+/*
+	Compile regexps for a test filter
+*/
+func regexps(rgxps ...string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+
+	for _, rgxp := range rgxps {
+		compiled = append(compiled, regexp.MustCompile(rgxp))
+	}
+	return compiled
+}
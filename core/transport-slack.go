@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Slack transport
+
+	POST the event to a Slack incoming webhook
+*/
+type SlackTransport struct {
+	name     string
+	url      string
+	channel  string
+	username string
+	icon     string
+}
+
+/*
+	Slack message
+*/
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+/*
+	Slack message attachment
+*/
+type slackAttachment struct {
+	Pretext string       `json:"pretext"`
+	Color   string       `json:"color"`
+	Fields  []slackField `json:"fields"`
+}
+
+/*
+	Slack attachment field
+*/
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+var (
+	// Slack colors by severity
+	slackColors = map[int]string{
+		dguard.EventNotice:   "#05c1ff",
+		dguard.EventWarning:  "#ffff00",
+		dguard.EventCritical: "#ff0000",
+	}
+)
+
+/*
+	Make a Slack transport
+*/
+func NewSlackTransport(c TransportConfig) (*SlackTransport, error) {
+	if c.URL == "" {
+		return nil, errors.New("Transport " + c.Name + ": no url")
+	}
+
+	return &SlackTransport{
+		name:     c.Name,
+		url:      c.URL,
+		channel:  c.Channel,
+		username: c.Username,
+		icon:     c.Icon,
+	}, nil
+}
+
+/*
+	Return the transport name
+*/
+func (t *SlackTransport) Name() string {
+	return t.name
+}
+
+/*
+	POST the event to Slack
+*/
+func (t *SlackTransport) Send(ctx context.Context, event EventMessage) error {
+	var message = slackMessage{
+		Channel:   t.channel,
+		Username:  t.username,
+		IconEmoji: t.icon,
+		Attachments: []slackAttachment{{
+			Pretext: "New " + strings.ToUpper(event.SeverityName) + " " + event.Type + " alert:",
+			Color:   slackColors[event.Severity],
+			Fields: []slackField{
+				{Title: "Target(s)", Value: event.Target},
+				{Title: "Probe", Value: event.Probe},
+				{Title: "Additional data", Value: event.Data},
+			},
+		}},
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return errors.New("Failed to marshal Slack message: " + err.Error())
+	}
+
+	return postJSON(ctx, t.url, body)
+}
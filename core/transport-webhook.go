@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+
+	"../utils"
+)
+
+/*
+	Webhook transport
+
+	Send the event message to an URL, in JSON or with a template
+	(text/template over EventMessage fields: {{.Severity}}, {{.SeverityName}},
+	{{.Type}}, {{.Target}}, {{.Probe}}, {{.Data}} and {{.Time}}; the json
+	function escapes a value in JSON, like {{json .Data}})
+*/
+type WebhookTransport struct {
+	name     string
+	url      string
+	method   string
+	headers  map[string]string
+	template *template.Template
+}
+
+var (
+	// Functions usable in webhook templates
+	webhookFuncs = template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+)
+
+/*
+	Make a webhook transport
+*/
+func NewWebhookTransport(c TransportConfig) (*WebhookTransport, error) {
+	var t = WebhookTransport{
+		name:    c.Name,
+		url:     c.URL,
+		method:  c.Method,
+		headers: c.Headers,
+	}
+	var err error // Error handling
+
+	if c.URL == "" {
+		return nil, errors.New("Transport " + c.Name + ": no url")
+	}
+	if t.method == "" {
+		t.method = "POST"
+	}
+	if c.Template != "" {
+		t.template, err = template.New(c.Name).Funcs(webhookFuncs).Parse(c.Template)
+		if err != nil {
+			return nil, errors.New("Transport " + c.Name + ": bad template: " + err.Error())
+		}
+	}
+
+	return &t, nil
+}
+
+/*
+	Return the transport name
+*/
+func (t *WebhookTransport) Name() string {
+	return t.name
+}
+
+/*
+	Send the event to the webhook
+	(a non 2xx HTTP status code is an error: the dispatcher retries it)
+*/
+func (t *WebhookTransport) Send(ctx context.Context, message EventMessage) error {
+	var body bytes.Buffer // Request body
+	var err error         // Error handling
+
+	// Make body
+	if t.template != nil {
+		err = t.template.Execute(&body, message)
+		if err != nil {
+			return errors.New("Failed to execute template: " + err.Error())
+		}
+	} else {
+		tmpJSON, err := json.Marshal(message)
+		if err != nil {
+			return errors.New("Failed to marshal event: " + err.Error())
+		}
+		body.Write(tmpJSON)
+	}
+
+	// Send request
+	return doRequest(ctx, t.method, t.url, t.headers, body.Bytes())
+}
+
+/*
+	POST a JSON body to an URL
+*/
+func postJSON(ctx context.Context, url string, body []byte) error {
+	return doRequest(ctx, "POST", url, nil, body)
+}
+
+/*
+	Do a HTTP request and check its status code
+	(the default Content-Type is application/json)
+*/
+func doRequest(ctx context.Context, method string, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := TransportHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return errors.New("HTTP status code " + utils.I2S(resp.StatusCode) + ": " + string(respBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Request received by a test webhook
+*/
+type webhookRequest struct {
+	Method  string
+	Headers http.Header
+	Body    string
+}
+
+/*
+	Start a test webhook answering the status codes in order (then 200)
+*/
+func newTestWebhook(statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	var requests []webhookRequest
+	var mutex sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, webhookRequest{r.Method, r.Header, string(body)})
+		if len(requests) <= len(statuses) {
+			w.WriteHeader(statuses[len(requests)-1])
+		}
+	}))
+
+	return server, func() []webhookRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]webhookRequest(nil), requests...)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	server, requests := newTestWebhook()
+	defer server.Close()
+
+	transport, err := NewWebhookTransport(TransportConfig{
+		Name:     "test",
+		URL:      server.URL,
+		Method:   "PUT",
+		Headers:  map[string]string{"X-Token": "secret", "Content-Type": "text/plain"},
+		Template: `{"text": {{json .Data}}, "severity": "{{.SeverityName}}", "type": "{{.Type}}", "probe": "{{.Probe}}"}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{
+		Severity: dguard.EventCritical,
+		Type:     EventProbeUnreachable,
+		Target:   "probe1",
+		Probe:    "probe1",
+		Data:     `last error: "connection refused"`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := requests()
+	if len(received) != 1 {
+		t.Fatalf("%d requests received, expected 1", len(received))
+	}
+	r := received[0]
+	if r.Method != "PUT" {
+		t.Errorf("method = %s, expected PUT", r.Method)
+	}
+	if r.Headers.Get("X-Token") != "secret" || r.Headers.Get("Content-Type") != "text/plain" {
+		t.Errorf("headers = %v, expected the configured headers", r.Headers)
+	}
+	expected := `{"text": "last error: \"connection refused\"", "severity": "Critical", "type": "ProbeUnreachable", "probe": "probe1"}`
+	if r.Body != expected {
+		t.Errorf("body = %s, expected %s", r.Body, expected)
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	var message EventMessage
+
+	server, requests := newTestWebhook()
+	defer server.Close()
+
+	transport, err := NewWebhookTransport(TransportConfig{Name: "test", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{
+		Severity: dguard.EventNotice,
+		Type:     EventProbeRecovered,
+		Target:   "probe1",
+		Probe:    "probe1",
+		Data:     "Down for 5m0s"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := requests()[0]
+	if r.Method != "POST" || r.Headers.Get("Content-Type") != "application/json" {
+		t.Errorf("request = %s %s, expected POST application/json", r.Method, r.Headers.Get("Content-Type"))
+	}
+	err = json.Unmarshal([]byte(r.Body), &message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Type != "ProbeRecovered" || message.SeverityName != "Notice" || message.Data != "Down for 5m0s" {
+		t.Errorf("message = %+v", message)
+	}
+}
+
+func TestWebhookStatusError(t *testing.T) {
+	server, _ := newTestWebhook(503)
+	defer server.Close()
+
+	transport, err := NewWebhookTransport(TransportConfig{Name: "test", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transport.Send(context.Background(), NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("error = %v, expected the status code", err)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	server, requests := newTestWebhook(500, 502)
+	defer server.Close()
+
+	rt, err := NewRoutedTransport(TransportConfig{Name: "test", Type: TransportWebhook, URL: server.URL, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatchQueue == nil {
+		InitDispatcher()
+	}
+
+	// Failed requests are retried by the dispatcher
+	Dispatch(rt, NewEventMessage(dguard.Event{Type: EventProbeRecovered}))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*DispatchRetryDelay+5*time.Second)
+	defer cancel()
+	if !waitGroup(ctx, &dispatchPending) {
+		t.Fatal("alert not sent")
+	}
+
+	if n := len(requests()); n != 3 {
+		t.Fatalf("%d requests received, expected 3", n)
+	}
+
+	// The retries carry the time of the event
+	for i, r := range requests()[1:] {
+		if r.Body != requests()[0].Body {
+			t.Errorf("retry %d body = %s, expected %s", i+1, r.Body, requests()[0].Body)
+		}
+	}
+}
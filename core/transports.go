@@ -0,0 +1,258 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Transport types
+*/
+const (
+	TransportExec    = "exec"
+	TransportWebhook = "webhook"
+	TransportSlack   = "slack"
+	TransportEmail   = "email"
+)
+
+/*
+	Transport config
+
+	Type is the transport type (exec by default), Filter selects the events
+	sent by the transport, Timeout is the max duration of a sending ("10s",
+	"1m", ...), Retries overrides the event retries of the config (-1 = no
+	retry), and the other fields are used depending on the type:
+	- exec: Path
+	- webhook: URL, Method, Headers, Template
+	- slack: URL, Channel, Username, Icon
+	- email: SMTPHost, SMTPPort, SMTPUser, SMTPPassword, From, To, Digest
+*/
+type TransportConfig struct {
+	Name         string                `yaml:"name"`
+	Type         string                `yaml:"type"`
+	Timeout      string                `yaml:"timeout"`
+	Path         string                `yaml:"path"`
+	URL          string                `yaml:"url"`
+	Method       string                `yaml:"method"`
+	Headers      map[string]string     `yaml:"headers"`
+	Template     string                `yaml:"template"`
+	Retries      int                   `yaml:"retries"`
+	Channel      string                `yaml:"channel"`
+	Username     string                `yaml:"username"`
+	Icon         string                `yaml:"icon"`
+	SMTPHost     string                `yaml:"smtp-host"`
+	SMTPPort     int                   `yaml:"smtp-port"`
+	SMTPUser     string                `yaml:"smtp-user"`
+	SMTPPassword string                `yaml:"smtp-password"`
+	From         string                `yaml:"from"`
+	To           []string              `yaml:"to"`
+	Digest       string                `yaml:"digest"`
+	Filter       TransportFilterConfig `yaml:",inline"`
+}
+
+/*
+	Transport used to send alerts
+	(the message is made once by the dispatcher: its time is the time of the
+	event, even if it's retried)
+*/
+type Transport interface {
+	Name() string
+	Send(ctx context.Context, message EventMessage) error
+}
+
+/*
+	Event sent by the transports
+*/
+type EventMessage struct {
+	Severity     int
+	SeverityName string
+	Type         string
+	Target       string
+	Probe        string
+	Data         string
+	Time         time.Time
+}
+
+/*
+	Transport which must be closed when it's not used anymore
+	(Close returns when ctx is done, even if the transport isn't closed)
+*/
+type ClosableTransport interface {
+	Close(ctx context.Context)
+}
+
+var (
+	// Transports used to send alerts
+	transports []RoutedTransport
+	// transports's Mutex
+	TransportsMutex sync.Mutex
+	// HTTP client used by transports
+	TransportHTTPClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+/*
+	Initialize transports
+*/
+func InitTransports() {
+	var err error // Error handling
+
+	// Lock / Unlock transports
+	TransportsMutex.Lock()
+	defer TransportsMutex.Unlock()
+
+	transports, err = BuildTransports(GetConfig().DockerGuard.Event.Transports, nil)
+	if err != nil {
+		l.Critical(err)
+	}
+}
+
+/*
+	Make the transports of a config
+	(the current transports with the same config are kept)
+*/
+func BuildTransports(configs []TransportConfig, current []RoutedTransport) ([]RoutedTransport, error) {
+	var built []RoutedTransport // Transports to return
+	var made []RoutedTransport  // New transports (closed on error)
+
+	for _, c := range configs {
+		var kept = false
+		for _, t := range current {
+			if reflect.DeepEqual(t.config, c) {
+				built = append(built, t)
+				kept = true
+				break
+			}
+		}
+		if kept {
+			continue
+		}
+
+		t, err := NewRoutedTransport(c)
+		if err != nil {
+			CloseTransports(context.Background(), made)
+			return nil, err
+		}
+		made = append(made, t)
+		built = append(built, t)
+	}
+
+	return built, nil
+}
+
+/*
+	Make a transport with its filter and timeout from its config
+*/
+func NewRoutedTransport(c TransportConfig) (RoutedTransport, error) {
+	var rt = RoutedTransport{config: c, Timeout: DefaultTransportTimeout}
+	var err error // Error handling
+
+	if c.Timeout != "" {
+		rt.Timeout, err = time.ParseDuration(c.Timeout)
+		if err != nil {
+			return rt, errors.New("Can't init transport " + c.Name + " timeout: " + err.Error())
+		}
+	}
+	rt.Filter, err = NewTransportFilter(c.Filter)
+	if err != nil {
+		return rt, errors.New("Can't init transport " + c.Name + " filter: " + err.Error())
+	}
+	rt.Transport, err = NewTransport(c, rt.Timeout)
+	if err != nil {
+		return rt, errors.New("Can't init transport: " + err.Error())
+	}
+
+	return rt, nil
+}
+
+/*
+	Replace the transports, and close the old ones which are not used anymore
+*/
+func SetTransports(newTransports []RoutedTransport) {
+	var unused []RoutedTransport // Old transports not used anymore
+
+	// Lock / Unlock transports
+	TransportsMutex.Lock()
+	for _, old := range transports {
+		var used = false
+		for _, t := range newTransports {
+			if t.Transport == old.Transport {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, old)
+		}
+	}
+	transports = newTransports
+	TransportsMutex.Unlock()
+
+	CloseTransports(context.Background(), unused)
+}
+
+/*
+	Get a copy of the transports list
+*/
+func GetTransports() []RoutedTransport {
+	// Lock / Unlock transports
+	TransportsMutex.Lock()
+	defer TransportsMutex.Unlock()
+
+	return append([]RoutedTransport(nil), transports...)
+}
+
+/*
+	Close the closable transports (until ctx is done)
+*/
+func CloseTransports(ctx context.Context, list []RoutedTransport) {
+	for _, t := range list {
+		if c, ok := t.Transport.(ClosableTransport); ok {
+			c.Close(ctx)
+		}
+	}
+}
+
+/*
+	Make a transport from its config
+	(timeout is used by the transports sending on their own, like the email
+	digests)
+*/
+func NewTransport(c TransportConfig, timeout time.Duration) (Transport, error) {
+	if c.Name == "" {
+		return nil, errors.New("Transport without name")
+	}
+
+	switch c.Type {
+	case TransportExec, "":
+		return NewExecTransport(c)
+	case TransportWebhook:
+		return NewWebhookTransport(c)
+	case TransportSlack:
+		return NewSlackTransport(c)
+	case TransportEmail:
+		return NewEmailTransport(c, timeout)
+	}
+
+	return nil, errors.New("Transport " + c.Name + ": unknown type " + c.Type)
+}
+
+/*
+	Make the message of an event
+*/
+func NewEventMessage(event dguard.Event) EventMessage {
+	return EventMessage{
+		Severity:     event.Severity,
+		SeverityName: SeverityToString(event.Severity),
+		Type:         EventTypeToString(event),
+		Target:       event.Target,
+		Probe:        event.Probe,
+		Data:         event.Data,
+		Time:         time.Now(),
+	}
+}
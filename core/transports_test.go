@@ -0,0 +1,77 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTransportsValidation(t *testing.T) {
+	var tests = []struct {
+		name    string
+		config  TransportConfig
+		invalid string // Expected error part, empty if valid
+	}{
+		{"exec", TransportConfig{Name: "exec", Path: "/bin/true"}, ""},
+		{"default type", TransportConfig{Name: "exec", Type: "", Path: "/bin/true"}, ""},
+		{"no name", TransportConfig{Path: "/bin/true"}, "without name"},
+		{"unknown type", TransportConfig{Name: "t", Type: "sms"}, "unknown type sms"},
+		{"exec without path", TransportConfig{Name: "t", Type: TransportExec}, "no path"},
+		{"webhook", TransportConfig{Name: "t", Type: TransportWebhook, URL: "http://127.0.0.1/hook"}, ""},
+		{"webhook without url", TransportConfig{Name: "t", Type: TransportWebhook}, "no url"},
+		{"webhook bad template", TransportConfig{Name: "t", Type: TransportWebhook, URL: "http://127.0.0.1/hook", Template: "{{.Target"}, "bad template"},
+		{"slack without url", TransportConfig{Name: "t", Type: TransportSlack}, "no url"},
+		{"email without smtp-host", TransportConfig{Name: "t", Type: TransportEmail, From: "a@b.c", To: []string{"d@e.f"}}, "no smtp-host"},
+		{"email without to", TransportConfig{Name: "t", Type: TransportEmail, SMTPHost: "localhost", From: "a@b.c"}, "no from or to"},
+		{"bad timeout", TransportConfig{Name: "t", Path: "/bin/true", Timeout: "10"}, "timeout"},
+		{"bad severity filter", TransportConfig{Name: "t", Path: "/bin/true", Filter: TransportFilterConfig{MinSeverity: "fatal"}}, "filter"},
+		{"bad type filter", TransportConfig{Name: "t", Path: "/bin/true", Filter: TransportFilterConfig{Types: []string{"Unknown"}}}, "filter"},
+		{"bad regexp filter", TransportConfig{Name: "t", Path: "/bin/true", Filter: TransportFilterConfig{Containers: []string{"db("}}}, "filter"},
+	}
+
+	for _, test := range tests {
+		built, err := BuildTransports([]TransportConfig{test.config}, nil)
+		if test.invalid == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			} else if len(built) != 1 {
+				t.Errorf("%s: %d transports built, expected 1", test.name, len(built))
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.invalid) {
+			t.Errorf("%s: error = %v, expected %q", test.name, err, test.invalid)
+		}
+	}
+}
+
+func TestBuildTransportsKeepsUnchanged(t *testing.T) {
+	var configs = []TransportConfig{
+		{Name: "kept", Path: "/bin/true"},
+		{Name: "changed", Path: "/bin/true"},
+	}
+
+	current, err := BuildTransports(configs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configs[1].Path = "/bin/false"
+	configs = append(configs, TransportConfig{Name: "added", Path: "/bin/true"})
+	built, err := BuildTransports(configs, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(built) != 3 {
+		t.Fatalf("%d transports built, expected 3", len(built))
+	}
+	if built[0].Transport != current[0].Transport {
+		t.Error("unchanged transport was made again")
+	}
+	if built[1].Transport == current[1].Transport {
+		t.Error("changed transport was kept")
+	}
+	if built[2].Name() != "added" {
+		t.Errorf("added transport name = %q", built[2].Name())
+	}
+}
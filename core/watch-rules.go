@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+/*
+	Watch rule
+
+	A container matches the rule if its image, probe name and hostname match
+	the Image, Probe and Hostname regexps and its IP address is in Subnet
+	(CIDR notation, like "10.0.0.0/8"). Empty fields match every container.
+*/
+type WatchRule struct {
+	Image    string `yaml:"image"`
+	Probe    string `yaml:"probe"`
+	Hostname string `yaml:"hostname"`
+	Subnet   string `yaml:"subnet"`
+	image    *regexp.Regexp
+	probe    *regexp.Regexp
+	hostname *regexp.Regexp
+	subnet   *net.IPNet
+}
+
+/*
+	Check if the watch rule is valid and compile its regexps
+	(the error lists every invalid field)
+*/
+func (r *WatchRule) Check() error {
+	var errs []string // Invalid fields
+	var err error     // Error handling
+
+	for _, f := range []struct {
+		name  string
+		rgxp  string
+		field **regexp.Regexp
+	}{
+		{"image", r.Image, &r.image},
+		{"probe", r.Probe, &r.probe},
+		{"hostname", r.Hostname, &r.hostname},
+	} {
+		*f.field = nil
+		if f.rgxp == "" {
+			continue
+		}
+		*f.field, err = regexp.Compile(f.rgxp)
+		if err != nil {
+			errs = append(errs, "bad "+f.name+" regexp "+strconv.Quote(f.rgxp)+": "+err.Error())
+		}
+	}
+	r.subnet = nil
+	if r.Subnet != "" {
+		_, r.subnet, err = net.ParseCIDR(r.Subnet)
+		if err != nil {
+			errs = append(errs, "bad subnet: "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+/*
+	Check if the watch rule has no field (it matches every container)
+*/
+func (r *WatchRule) Empty() bool {
+	return r.Image == "" && r.Probe == "" && r.Hostname == "" && r.Subnet == ""
+}
+
+/*
+	Check if a container matches the watch rule
+*/
+func (r *WatchRule) Match(c dguard.Container) bool {
+	if r.image != nil && !r.image.MatchString(c.Image) {
+		return false
+	}
+	if r.probe != nil && !r.probe.MatchString(c.Probe) {
+		return false
+	}
+	if r.hostname != nil && !r.hostname.MatchString(c.Hostname) {
+		return false
+	}
+	if r.subnet != nil {
+		ip := net.ParseIP(c.IPAddress)
+		if ip == nil || !r.subnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+	Get the container targeted by an event
+	(only its hostname, ID and probe are known if it's not in containerList)
+*/
+func eventContainer(event dguard.Event) dguard.Container {
+	hostname, id := splitTarget(event.Target, event.Probe)
+	c, err := GetContainerByCID(id)
+	if err != nil || c.Probe != event.Probe {
+		c = dguard.Container{
+			ID:       id,
+			Hostname: hostname,
+		}
+	}
+	c.Probe = event.Probe
+	return c
+}
+
+/*
+	Check if a container matches one of the watch rules
+*/
+func matchWatchRules(rules []WatchRule, c dguard.Container) bool {
+	for i := range rules {
+		if rules[i].Match(c) {
+			return true
+		}
+	}
+	return false
+}
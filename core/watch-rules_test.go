@@ -0,0 +1,88 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	dguard "github.com/90TechSAS/libgo-docker-guard"
+)
+
+func TestWatchRuleMatch(t *testing.T) {
+	var c = dguard.Container{
+		ID:        "abc123",
+		Hostname:  "db-1",
+		Image:     "postgres:9.5",
+		Probe:     "prod-1",
+		IPAddress: "10.0.3.4"}
+
+	var tests = []struct {
+		name  string
+		rule  WatchRule
+		match bool
+	}{
+		{"image matched", WatchRule{Image: "^postgres:"}, true},
+		{"image not matched", WatchRule{Image: "^mysql"}, false},
+		{"probe matched", WatchRule{Probe: "^prod-"}, true},
+		{"probe not matched", WatchRule{Probe: "^dev-"}, false},
+		{"hostname matched", WatchRule{Hostname: "^db-[0-9]+$"}, true},
+		{"hostname not matched", WatchRule{Hostname: "^web-"}, false},
+		{"subnet matched", WatchRule{Subnet: "10.0.0.0/8"}, true},
+		{"subnet not matched", WatchRule{Subnet: "192.168.0.0/16"}, false},
+		{"all fields matched", WatchRule{Image: "postgres", Probe: "prod", Hostname: "db", Subnet: "10.0.3.0/24"}, true},
+		{"one field not matched", WatchRule{Image: "postgres", Probe: "prod", Hostname: "db", Subnet: "10.0.4.0/24"}, false},
+	}
+
+	for _, test := range tests {
+		err := test.rule.Check()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if match := test.rule.Match(c); match != test.match {
+			t.Errorf("%s: Match = %v, expected %v", test.name, match, test.match)
+		}
+	}
+
+	// A container without IP address is not in any subnet
+	var rule = WatchRule{Subnet: "0.0.0.0/0"}
+	rule.Check()
+	if rule.Match(dguard.Container{Hostname: "db-1"}) {
+		t.Error("container without IP address matched a subnet")
+	}
+}
+
+func TestWatchRuleCheck(t *testing.T) {
+	var tests = []struct {
+		name  string
+		rule  WatchRule
+		valid bool
+	}{
+		{"valid", WatchRule{Image: "^postgres", Subnet: "10.0.0.0/8"}, true},
+		{"bad image regexp", WatchRule{Image: "postgres("}, false},
+		{"bad hostname regexp", WatchRule{Hostname: "[db"}, false},
+		{"bad subnet", WatchRule{Subnet: "10.0.0.0"}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.rule.Check(); (err == nil) != test.valid {
+			t.Errorf("%s: Check() = %v, expected valid = %v", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestEmptyIgnoreRuleRejected(t *testing.T) {
+	var c Config
+
+	c.DockerGuard.Event.WatchRules = []WatchRule{{}}
+	c.DockerGuard.Event.IgnoreRules = []WatchRule{{Image: "^busybox"}, {}}
+
+	err := compileWatch(&c)
+	if err == nil || !strings.Contains(err.Error(), "ignore-rules #2: empty rule") {
+		t.Errorf("error = %v, expected the empty ignore rule", err)
+	}
+
+	c.DockerGuard.Event.IgnoreRules = c.DockerGuard.Event.IgnoreRules[:1]
+	if err = compileWatch(&c); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -21,6 +21,14 @@ if [ $# -lt 5 ] || [ $# -gt 5 ] ; then
         echo "				DiskIOOverload"
         echo "				NetBandwithOverload"
         echo "				CPUUsageOverload"
+        echo "				AlertResolved"
+        echo "				ProbeUnreachable"
+        echo "				ProbeRecovered"
+        echo "				ContainerFlapping"
+        echo "				ContainerReplaced"
+        echo "				ContainerImageChanged"
+        echo "				MetricAnomaly"
+        echo "				MemoryLeakSuspected"
         echo 
         echo "target		Targeted system(s)"
         echo 
@@ -89,6 +97,30 @@ case "$2" in
     "CPUUsageOverload")
         THUMB=$THUMBCPUUsageOverload
         ;;
+    "AlertResolved")
+        THUMB=$THUMBAlertResolved
+        ;;
+    "ProbeUnreachable")
+        THUMB=$THUMBProbeUnreachable
+        ;;
+    "ProbeRecovered")
+        THUMB=$THUMBProbeRecovered
+        ;;
+    "ContainerFlapping")
+        THUMB=$THUMBContainerFlapping
+        ;;
+    "ContainerReplaced")
+        THUMB=$THUMBContainerReplaced
+        ;;
+    "ContainerImageChanged")
+        THUMB=$THUMBContainerImageChanged
+        ;;
+    "MetricAnomaly")
+        THUMB=$THUMBMetricAnomaly
+        ;;
+    "MemoryLeakSuspected")
+        THUMB=$THUMBMemoryLeakSuspected
+        ;;
     *)
         echo "Error: Type unknow"
         exit 1
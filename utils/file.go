@@ -3,6 +3,7 @@ package utils
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 /*
@@ -23,16 +24,51 @@ func FileReadAllBytes(filepath string) ([]byte, error) {
 
 /*
 	Write a string in a file.
+	(see FileWriteAllBytes)
 */
-func FileWriteAll(filepath, content string) error {
-	return ioutil.WriteFile(filepath, []byte(content), 0600)
+func FileWriteAll(path, content string) error {
+	return FileWriteAllBytes(path, []byte(content))
 }
 
 /*
 	Write a []byte in a file.
+	(the content is written in a temporary file of the same dir, synced and
+	renamed, so the file is never left partially written)
 */
-func FileWriteAllBytes(filepath string, content []byte) error {
-	return ioutil.WriteFile(filepath, content, 0600)
+func FileWriteAllBytes(path string, content []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+/*
+	Append a []byte to a file (the file is created if it doesn't exist).
+*/
+func FileAppendBytes(filepath string, content []byte) error {
+	f, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 /*